@@ -0,0 +1,88 @@
+package goemon
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// BenchResult is result of Bench
+type BenchResult struct {
+	Events  int
+	Matches int
+	Runs    int
+	Elapsed time.Duration
+	P50     time.Duration
+	P95     time.Duration
+	Max     time.Duration
+}
+
+func (r *BenchResult) String() string {
+	sec := r.Elapsed.Seconds()
+	if sec == 0 {
+		sec = 1e-9
+	}
+	return fmt.Sprintf("%d events, %d matches, %d runs in %v (%.0f events/s, %.0f matches/s), dispatch latency p50=%v p95=%v max=%v",
+		r.Events, r.Matches, r.Runs, r.Elapsed, float64(r.Events)/sec, float64(r.Matches)/sec, r.P50, r.P95, r.Max)
+}
+
+// Bench replay the event stream read from r against the loaded configuration
+// as fast as possible, and measure throughput of dispatching. Events go
+// through the same path as events of files, including min_interval, but
+// commands are not run. Latencies are time from the event to the start of
+// the run of each task. Events for running tasks are dropped like usual, so
+// runs can be less than matches.
+func (g *Goemon) Bench(r io.Reader) (*BenchResult, error) {
+	res, err := readEvents(r)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, len(res))
+	for i := range res {
+		if events[i], err = res[i].event(); err != nil {
+			return nil, err
+		}
+	}
+
+	var result BenchResult
+	for _, event := range events {
+		result.Matches += len(g.matchTasks(event))
+	}
+
+	var mutex sync.Mutex
+	var latencies []time.Duration
+	g.benchStart = func(t *task, at time.Time) {
+		d := time.Since(at)
+		mutex.Lock()
+		latencies = append(latencies, d)
+		mutex.Unlock()
+	}
+	defer func() {
+		g.benchStart = nil
+	}()
+	start := time.Now()
+	for _, event := range events {
+		event.Time = time.Now()
+		g.task(event)
+	}
+	g.fired.Wait()
+	result.Elapsed = time.Since(start)
+	result.Events = len(events)
+	result.Runs = len(latencies)
+	if len(latencies) > 0 {
+		sort.Slice(latencies, func(i, j int) bool {
+			return latencies[i] < latencies[j]
+		})
+		result.P50 = percentile(latencies, 50)
+		result.P95 = percentile(latencies, 95)
+		result.Max = latencies[len(latencies)-1]
+	}
+	return &result, nil
+}
+
+// percentile return p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p int) time.Duration {
+	return sorted[(len(sorted)-1)*p/100]
+}
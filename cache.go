@@ -0,0 +1,212 @@
+package goemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/goemon/config"
+	"github.com/mattn/goemon/watcher"
+)
+
+// taskCache hold hashes of inputs of the last successful run for each task.
+// It is persisted in the cache directory of the user.
+type taskCache struct {
+	mutex   sync.Mutex
+	path    string
+	entries map[string]string
+}
+
+func cachePath(file string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(file))
+	return filepath.Join(dir, "goemon", hex.EncodeToString(sum[:8])+".json")
+}
+
+func (c *taskCache) reset(path string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.path = path
+	c.entries = nil
+}
+
+func (c *taskCache) load() {
+	if c.entries != nil {
+		return
+	}
+	c.entries = map[string]string{}
+	if c.path == "" {
+		return
+	}
+	if b, err := ioutil.ReadFile(c.path); err == nil {
+		json.Unmarshal(b, &c.entries)
+	}
+}
+
+func (c *taskCache) get(key string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.load()
+	return c.entries[key]
+}
+
+func (c *taskCache) put(key, hash string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.load()
+	c.entries[key] = hash
+	if c.path == "" {
+		return nil
+	}
+	b, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.path, b, 0644)
+}
+
+// key return identifier of the task which is stable across restarts. It
+// contains all of the configuration which affects the run, so the cache is
+// not used after the configuration of the task is changed.
+func (t *task) key() string {
+	b, _ := json.Marshal(struct {
+		Match, Ignore, Dir   string
+		Commands, Pipe, Ops  []string
+		OnSuccess, OnFailure []string
+		Restart              []string
+		Timeout              time.Duration
+		MaxOutput            int64
+		ProblemMatcher       *config.ProblemMatcher
+	}{
+		t.Match, t.Ignore, t.dir,
+		t.Commands, t.Pipe, t.Ops,
+		t.OnSuccess, t.OnFailure,
+		t.Restart,
+		t.timeout,
+		t.maxOutput,
+		t.ProblemMatcher,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// startCached run tasks which have cache when files matched by them are
+// changed since the last successful run, maybe before goemon started. Tasks
+// of which inputs are not changed are not run, so starting is fast.
+func (g *Goemon) startCached() {
+	for _, t := range g.conf.Tasks {
+		if !t.Cache || strings.HasPrefix(t.Match, ":") {
+			continue
+		}
+		hash := g.inputHash(t)
+		if hash == "" || g.cache.get(t.key()) == hash {
+			continue
+		}
+		if g.fire(t, "", time.Time{}) {
+			g.Logger.Println("running", t.Match, "because inputs are changed since the last run")
+		}
+	}
+}
+
+// fileHash is hash of the file with its size and modification time. The
+// file is not read again while they are not changed.
+type fileHash struct {
+	size    int64
+	modTime time.Time
+	sum     [sha256.Size]byte
+}
+
+// fileHashes hold hashes of files read for inputHash
+type fileHashes struct {
+	mutex sync.Mutex
+	files map[string]fileHash
+}
+
+func (h *fileHashes) sum(path string, info os.FileInfo) ([sha256.Size]byte, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if fh, ok := h.files[path]; ok && fh.size == info.Size() && fh.modTime.Equal(info.ModTime()) {
+		return fh.sum, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	if h.files == nil {
+		h.files = map[string]fileHash{}
+	}
+	fh := fileHash{size: info.Size(), modTime: info.ModTime(), sum: sha256.Sum256(b)}
+	h.files[path] = fh
+	return fh.sum, nil
+}
+
+// inputHash return hash of all files which match the task. Only directories
+// which can contain matched files are walked. It returns empty string if the
+// hash can't be computed.
+func (g *Goemon) inputHash(t *task) string {
+	if t.matcher.Match == nil {
+		return ""
+	}
+	roots := watcher.PatternRoots(t.dir, t.Match)
+	if roots == nil {
+		root, err := filepath.Abs(".")
+		if err != nil {
+			return ""
+		}
+		roots = []string{root}
+	}
+	sums := map[string][sha256.Size]byte{}
+	for _, root := range roots {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if info == nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				if info.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			file := filepath.ToSlash(path)
+			if _, ok := sums[file]; ok || !t.match(file) {
+				return nil
+			}
+			sums[file], err = g.hashes.sum(path, info)
+			return err
+		})
+		if err != nil {
+			g.Logger.Println(err)
+			return ""
+		}
+	}
+
+	files := make([]string, 0, len(sums))
+	for file := range sums {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	h := sha256.New()
+	for _, file := range files {
+		sum := sums[file]
+		io.WriteString(h, file+"\x00")
+		h.Write(sum[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,152 @@
+package goemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/mattn/goemon/commands"
+)
+
+// sourceExts are extensions of files which are usually compiled into other
+// files. Reloading browser for them is a mistake in most cases.
+var sourceExts = map[string]bool{
+	".go":     true,
+	".ts":     true,
+	".tsx":    true,
+	".jsx":    true,
+	".coffee": true,
+	".scss":   true,
+	".sass":   true,
+	".less":   true,
+	".elm":    true,
+	".vue":    true,
+}
+
+// Check load the configuration and return warnings for common mistakes
+func (g *Goemon) Check() ([]string, error) {
+	if err := g.load(); err != nil {
+		return nil, err
+	}
+
+	// files in roots of tasks out of the current directory are listed too
+	// like watch
+	files, err := listFiles(".")
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := filepath.Abs(".")
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range g.roots() {
+		if within(cwd, dir) {
+			continue
+		}
+		more, err := listFiles(dir)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, more...)
+	}
+
+	var warnings []string
+	matched := make([][]string, len(g.conf.Tasks))
+	for i, t := range g.conf.Tasks {
+		if len(t.Commands) == 0 && len(t.Pipe) == 0 && len(t.OnSuccess) == 0 && t.WatchURL == "" && t.WatchCmd == "" {
+			warnings = append(warnings, fmt.Sprintf("task %q has no commands", t.Match))
+		}
+		if strings.HasPrefix(t.Match, ":") || t.matcher.Match == nil {
+			continue
+		}
+		for _, file := range files {
+			if t.match(file) {
+				matched[i] = append(matched[i], file)
+			}
+		}
+		if len(matched[i]) == 0 {
+			warnings = append(warnings, fmt.Sprintf("task %q matches no existing files", t.Match))
+		}
+		if t.reloadsSource(matched[i]) {
+			warnings = append(warnings, fmt.Sprintf("task %q reloads browser for source files without building them", t.Match))
+		}
+	}
+
+	for i := 0; i < len(g.conf.Tasks); i++ {
+		for j := i + 1; j < len(g.conf.Tasks); j++ {
+			ti, tj := g.conf.Tasks[i], g.conf.Tasks[j]
+			if reflect.DeepEqual(ti.Commands, tj.Commands) {
+				continue
+			}
+			if overlap(matched[i], matched[j]) {
+				warnings = append(warnings, fmt.Sprintf("tasks %q and %q match same files with different commands", ti.Match, tj.Match))
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// reloadsSource return true when the task only reload browser for source
+// files.
+func (t *task) reloadsSource(files []string) bool {
+	reload := false
+	for _, command := range t.Commands {
+		if !commands.IsCommand(command) {
+			return false
+		}
+		name, _ := commands.Parse(command)
+		switch name {
+		case ":livereload":
+			reload = true
+		case ":minify":
+			return false
+		}
+	}
+	if !reload {
+		return false
+	}
+	for _, file := range files {
+		if sourceExts[strings.ToLower(filepath.Ext(file))] {
+			return true
+		}
+	}
+	return false
+}
+
+// listFiles return absolute paths of files under root except .git
+func listFiles(root string) ([]string, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if info == nil {
+			return err
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files = append(files, filepath.ToSlash(path))
+		return nil
+	})
+	return files, err
+}
+
+func overlap(a, b []string) bool {
+	m := map[string]bool{}
+	for _, s := range a {
+		m[s] = true
+	}
+	for _, s := range b {
+		if m[s] {
+			return true
+		}
+	}
+	return false
+}
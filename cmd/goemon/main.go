@@ -4,16 +4,21 @@ package main
 //go:generate statik
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime"
 	"sort"
 
 	"github.com/mattn/goemon"
 	_ "github.com/mattn/goemon/cmd/goemon/statik"
+	"github.com/mattn/goemon/config"
+	"github.com/mattn/goemon/console"
+	"github.com/mattn/goemon/update"
 	"github.com/rakyll/statik/fs"
 )
 
@@ -23,10 +28,31 @@ const (
 	revision = "HEAD"
 )
 
+// publicKey is the public key of minisign which signs checksums.txt of
+// releases. It is same as minisign.pub.
+const publicKey = "RWTSyg34H9UPIY6BhNv+iAu+Q1yPIyYKocHUXbzwVI0iKE29+b9s4HG1"
+
 func usage() {
 	fmt.Printf("Usage of %s [options] [command] [args...]\n", os.Args[0])
-	fmt.Println(" goemon -g [NAME]     : generate default configuration")
-	fmt.Println(" goemon -c [FILE] ... : set configuration file")
+	fmt.Println(" goemon -g [NAME]                   : generate default configuration")
+	fmt.Println(" goemon -c [FILE] ...               : set configuration file")
+	fmt.Println(" goemon -config-optional ...        : run without configuration file until it is created")
+	fmt.Println(" goemon -minisign-key [KEY] ...     : verify FILE.minisig with minisign")
+	fmt.Println(" goemon -allowed-signers [FILE] ... : verify FILE.sig with ssh-keygen -Y")
+	fmt.Println(" goemon -allow [COMMAND] ...        : allow only listed commands to run")
+	fmt.Println(" goemon -record [FILE] ...          : record events into file")
+	fmt.Println(" goemon -replay [FILE] ...          : replay events recorded in file")
+	fmt.Println(" goemon -once ...                   : run tasks once for matched files and exit")
+	fmt.Println(" goemon -no-command ...             : run only tasks and livereload without command")
+	fmt.Println(" goemon check [FILE]                : check configuration file")
+	fmt.Println(" goemon bench [EVENTS] [FILE]       : benchmark dispatching recorded events")
+	fmt.Println(" goemon hook install [HOOK...]      : install git hooks to send changed files")
+	fmt.Println(" goemon service install [FILE]      : run goemon with the file when logged in")
+	fmt.Println(" goemon service uninstall [FILE]    : remove the service installed")
+	fmt.Println(" goemon --healthcheck [FILE]        : exit with 0 if running goemon is healthy")
+	fmt.Println(" goemon ctl which PATH [FILE]       : show tasks which run when PATH is saved")
+	fmt.Println(" goemon ctl problems [FILE]         : show problems found by running goemon")
+	fmt.Println(" goemon self-update [KEY]           : replace goemon with the latest release")
 	fmt.Println("")
 	fmt.Println("* Examples:")
 	fmt.Println("  Generate default configuration:")
@@ -42,7 +68,7 @@ func usage() {
 	fmt.Println("    goemon --")
 	fmt.Println("  Start web server:")
 	fmt.Println("    goemon -a :5000")
-	os.Exit(1)
+	exit(1)
 }
 
 var hfs http.FileSystem
@@ -51,7 +77,7 @@ func init() {
 	var err error
 	hfs, err = fs.New()
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 }
 
@@ -67,12 +93,12 @@ func asset(name string) ([]byte, error) {
 func names() []string {
 	dir, err := hfs.Open("/")
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	defer dir.Close()
 	fss, err := dir.Readdir(-1)
 	if err != nil {
-		log.Fatal(err)
+		fatal(err)
 	}
 	var files []string
 	for _, fsi := range fss {
@@ -81,64 +107,374 @@ func names() []string {
 	return files
 }
 
-func main() {
-	file := ""
-	args := []string{}
-	addr := ""
+func check(args []string) {
+	g := goemon.New()
+	if len(args) > 0 {
+		g.File = args[0]
+	}
+	warnings, err := g.Check()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	for _, w := range warnings {
+		fmt.Println("warning:", w)
+	}
+}
+
+func bench(args []string) {
+	if len(args) == 0 {
+		usage()
+	}
+	g := goemon.New()
+	g.Logger.SetOutput(ioutil.Discard)
+	if len(args) > 1 {
+		g.File = args[1]
+	}
+	if err := g.Load(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	defer f.Close()
+	result, err := g.Bench(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	fmt.Println(result)
+}
 
-	switch len(os.Args) {
-	case 1:
+func hook(args []string) {
+	if len(args) == 0 {
 		usage()
+	}
+	switch args[0] {
+	case "install":
+		if err := goemon.InstallHooks(args[1:]...); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+	case "run":
+		if len(args) < 2 {
+			usage()
+		}
+		files, err := goemon.HookFiles(args[1])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+		if len(files) == 0 {
+			return
+		}
+		g := goemon.New()
+		g.Logger.SetOutput(ioutil.Discard)
+		g.ConfigOptional = true
+		g.Load()
+		if err = g.Send(files); err != nil {
+			// goemon is not running
+			fmt.Fprintln(os.Stderr, "goemon:", err)
+		}
+	default:
+		usage()
+	}
+}
+
+func service(args []string) {
+	if len(args) == 0 {
+		usage()
+	}
+	file := ""
+	if len(args) > 1 {
+		file = args[1]
+	}
+	var err error
+	switch args[0] {
+	case "install":
+		err = goemon.InstallService(file)
+	case "uninstall":
+		err = goemon.UninstallService(file)
 	default:
-		switch os.Args[1] {
-		case "-h":
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+}
+
+func healthcheck(args []string) {
+	g := goemon.New()
+	g.Logger.SetOutput(ioutil.Discard)
+	g.ConfigOptional = true
+	if len(args) > 0 {
+		g.File = args[0]
+	}
+	g.Load()
+	if err := g.HealthCheck(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+}
+
+func ctl(args []string) {
+	if len(args) == 0 {
+		usage()
+	}
+	command := args[0]
+	args = args[1:]
+	asJSON := false
+	if len(args) > 0 && args[0] == "-json" {
+		asJSON = true
+		args = args[1:]
+	}
+	g := goemon.New()
+	g.Logger.SetOutput(ioutil.Discard)
+	g.ConfigOptional = true
+
+	switch command {
+	case "which":
+		if len(args) == 0 {
 			usage()
-		case "-g":
-			if len(os.Args) == 2 {
-				b, _ := asset("web.yml")
-				fmt.Print(string(string(b)))
-			} else if os.Args[2] == "?" {
-				keys := names()
-				sort.Strings(keys)
-				for _, k := range keys {
-					fmt.Println(k[:len(k)-4])
-				}
-			} else if t, err := asset(os.Args[2] + ".yml"); err == nil {
-				fmt.Print(string(t))
-			} else {
-				usage()
+		}
+		if len(args) > 1 {
+			g.File = args[1]
+		}
+		g.Load()
+		infos, err := g.Which(args[0])
+		if err != nil {
+			// goemon is not running, decide with the configuration
+			infos = g.WhichTasks(args[0])
+		}
+		if asJSON {
+			json.NewEncoder(os.Stdout).Encode(infos)
+			return
+		}
+		for _, info := range infos {
+			mark := "-"
+			if info.Fire {
+				mark = "+"
 			}
+			fmt.Printf("%s %s: %s\n", mark, info.Match, info.Reason)
+		}
+	case "problems":
+		if len(args) > 0 {
+			g.File = args[0]
+		}
+		g.Load()
+		problems, err := g.FetchProblems()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+		if asJSON {
+			json.NewEncoder(os.Stdout).Encode(problems)
 			return
-		case "-a":
-			if len(os.Args) == 2 {
-				usage()
-				return
+		}
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+	default:
+		usage()
+	}
+}
+
+func selfUpdate(args []string) {
+	u := update.New("mattn/goemon", name, version)
+	u.PublicKey = publicKey
+	if len(args) > 0 {
+		u.PublicKey = args[0]
+	}
+	v, err := u.Update()
+	if err == update.ErrUpToDate {
+		fmt.Println(name, version, "is", err)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	fmt.Println("updated to", v)
+}
+
+// exit restore the console and exit with code because os.Exit doesn't run
+// deferred functions
+func exit(code int) {
+	console.Restore()
+	os.Exit(code)
+}
+
+func fatal(v ...interface{}) {
+	log.Print(v...)
+	exit(1)
+}
+
+func main() {
+	defer console.Restore()
+
+	file := ""
+	addr := ""
+	record := ""
+	replay := ""
+	optional := false
+	once := false
+	noCommand := false
+	minisignKey := ""
+	allowedSigners := ""
+	var allowed []string
+
+	if len(os.Args) == 1 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "-h":
+		usage()
+	case "-g":
+		if len(os.Args) == 2 {
+			b, _ := asset("web.yml")
+			fmt.Print(string(string(b)))
+		} else if os.Args[2] == "?" {
+			keys := names()
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Println(k[:len(k)-4])
 			}
-			addr = os.Args[2]
-			args = os.Args[3:]
-		case "-c":
-			if len(os.Args) == 2 {
+		} else if t, err := asset(os.Args[2] + ".yml"); err == nil {
+			fmt.Print(string(t))
+		} else {
+			usage()
+		}
+		return
+	case "check":
+		check(os.Args[2:])
+		return
+	case "bench":
+		bench(os.Args[2:])
+		return
+	case "hook":
+		hook(os.Args[2:])
+		return
+	case "service":
+		service(os.Args[2:])
+		return
+	case "--healthcheck":
+		healthcheck(os.Args[2:])
+		return
+	case "ctl":
+		ctl(os.Args[2:])
+		return
+	case "self-update":
+		selfUpdate(os.Args[2:])
+		return
+	case "-v":
+		fmt.Printf("%s %s (rev: %s/%s)\n", name, version, revision, runtime.Version())
+		exit(1)
+	}
+
+	args := os.Args[1:]
+loop:
+	for len(args) > 0 {
+		switch args[0] {
+		case "-a", "-c", "-record", "-replay", "-minisign-key", "-allowed-signers", "-allow":
+			if len(args) == 1 {
 				usage()
-				return
 			}
-			file = os.Args[2]
-			args = os.Args[3:]
+			switch args[0] {
+			case "-a":
+				addr = args[1]
+			case "-c":
+				file = args[1]
+			case "-record":
+				record = args[1]
+			case "-replay":
+				replay = args[1]
+			case "-minisign-key":
+				minisignKey = args[1]
+			case "-allowed-signers":
+				allowedSigners = args[1]
+			case "-allow":
+				allowed = append(allowed, args[1])
+			}
+			args = args[2:]
+		case "-config-optional":
+			optional = true
+			args = args[1:]
+		case "-once":
+			once = true
+			args = args[1:]
+		case "-no-command":
+			noCommand = true
+			args = args[1:]
 		case "--":
-			args = os.Args[2:]
-		case "-v":
-			fmt.Printf("%s %s (rev: %s/%s)\n", name, version, revision, runtime.Version())
-			os.Exit(1)
+			args = args[1:]
+			break loop
 		default:
-			args = os.Args[1:]
+			break loop
 		}
 	}
 
+	if noCommand && len(args) > 0 {
+		usage()
+	}
 	g := goemon.NewWithArgs(args)
+	g.NoCommand = noCommand
 	if file != "" {
 		g.File = file
+	} else if _, err := os.Stat(g.File); os.IsNotExist(err) {
+		// zero-config mode
+		optional = true
+	}
+	g.ConfigOptional = optional
+	listed, err := config.LoadAllowedCommands()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	g.AllowedCommands = append(allowed, listed...)
+	if minisignKey != "" {
+		g.Verifier = &config.Minisign{PublicKey: minisignKey}
+	} else if allowedSigners != "" {
+		g.Verifier = &config.SSHSignature{AllowedSigners: allowedSigners}
+	}
+	if once {
+		if err := g.Once(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+		return
+	}
+	if record != "" {
+		f, err := os.Create(record)
+		if err != nil {
+			fatal(err)
+		}
+		defer f.Close()
+		g.Record(f)
+	}
+	if replay != "" {
+		f, err := os.Open(replay)
+		if err != nil {
+			fatal(err)
+		}
+		src, err := goemon.NewReplaySource(f)
+		f.Close()
+		if err != nil {
+			fatal(err)
+		}
+		g.AddEventSource(src)
 	}
 	g.Run()
 	if len(args) == 0 {
+		go func() {
+			sig := make(chan os.Signal, 1)
+			signal.Notify(sig, os.Interrupt)
+			<-sig
+			exit(0)
+		}()
 		if addr != "" {
 			http.Handle("/", http.FileServer(http.Dir(".")))
 			http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
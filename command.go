@@ -1,186 +1,267 @@
 package goemon
 
 import (
-	"io/ioutil"
-	"net"
-	"net/http"
+	"errors"
+	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"runtime"
-	"strconv"
+	"reflect"
 	"strings"
-	"time"
+	"sync/atomic"
 
-	"github.com/fsnotify/fsnotify"
-	"github.com/omeid/jsmin"
-	"github.com/omeid/livereload"
-	"github.com/tdewolff/minify"
-	"github.com/tdewolff/minify/css"
+	"github.com/mattn/goemon/commands"
+	"github.com/mattn/goemon/reload"
+	"github.com/mattn/goemon/runner"
 )
 
-func (g *Goemon) internalCommand(command, file string) bool {
-	ss := commandRe.FindStringSubmatch(command)
-	switch ss[1] {
-	case ":livereload":
-		for _, s := range ss[2:] {
+func (g *Goemon) builtinCommands() commands.Set {
+	set := commands.Builtin()
+	set[":livereload"] = commands.CommandFunc(func(ctx *commands.Context, args ...string) error {
+		r := g.reloaderFor(ctx.Dir)
+		for _, s := range args {
 			g.Logger.Println("reloading", s)
-			g.lrs.Reload(s, true)
-		}
-		return true
-	case ":sleep":
-		for _, s := range ss[2:] {
-			si, err := strconv.ParseInt(s, 10, 64)
-			if err != nil {
-				g.Logger.Println("failed to parse argument for :sleep command:", err)
-				return false
+			if r != nil {
+				r.Reload(s)
 			}
-			g.Logger.Println("sleeping", s+"ms")
-			time.Sleep(time.Duration(si) * time.Microsecond)
 		}
-		return true
-	case ":fizzbuzz":
-		for _, s := range ss[2:] {
-			si, err := strconv.ParseInt(s, 10, 64)
-			if err != nil {
-				g.Logger.Println("failed to parse argument for :fizzbuzz command:", err)
-				return false
-			}
-			for i := int64(1); i <= si; i++ {
-				switch {
-				case i%15 == 0:
-					g.Logger.Println("FizzBuzz")
-				case i%3 == 0:
-					g.Logger.Println("Fizz")
-				case i%5 == 0:
-					g.Logger.Println("Buzz")
-				default:
-					g.Logger.Println(i)
-				}
-			}
+		return nil
+	})
+	set[":restart"] = commands.CommandFunc(func(ctx *commands.Context, args ...string) error {
+		if len(args) > 0 {
+			return g.terminateProcesses(os.Interrupt, args)
 		}
-		return true
-	case ":minify":
-		return g.minify(file)
-	case ":restart!":
-		return g.terminate(os.Kill) == nil
-	case ":restart":
-		return g.terminate(os.Interrupt) == nil
-	case ":event":
-		for _, s := range ss[2:] {
+		return g.terminate(os.Interrupt)
+	})
+	set[":restart!"] = commands.CommandFunc(func(ctx *commands.Context, args ...string) error {
+		if len(args) > 0 {
+			return g.terminateProcesses(os.Kill, args)
+		}
+		return g.terminate(os.Kill)
+	})
+	// :event dispatch events at once like events of files, so they are
+	// never dropped. Commands after it run while the tasks are running.
+	set[":event"] = commands.CommandFunc(func(ctx *commands.Context, args ...string) error {
+		for _, s := range args {
 			g.Logger.Println("fire", s)
-			g.task(fsnotify.Event{Name: s, Op: fsnotify.Write})
+			g.dispatch(NewEvent(s, Write))
 		}
+		return nil
+	})
+	set[":confirm"] = commands.CommandFunc(func(ctx *commands.Context, args ...string) error {
+		if !g.confirm(strings.Join(args, " ")) {
+			return errors.New("not confirmed")
+		}
+		return nil
+	})
+	set[":gobuild"] = commands.CommandFunc(g.gobuild)
+	set[":gotest"] = commands.CommandFunc(g.gotest)
+	set[":lint"] = commands.CommandFunc(g.lint)
+	set[":migrate"] = commands.CommandFunc(g.migrate)
+	return set
+}
+
+// scope add processes in restart of the task into :restart without
+// arguments.
+func (t *task) scope(command string) string {
+	if t.Task == nil || len(t.Restart) == 0 {
+		return command
 	}
-	return false
+	name, args := commands.Parse(command)
+	if (name == ":restart" || name == ":restart!") && len(args) == 0 {
+		return name + " " + strings.Join(t.Restart, " ")
+	}
+	return command
 }
 
-func (g *Goemon) externalCommand(command, file string) bool {
-	var cmd *exec.Cmd
-	command = os.Expand(command, func(s string) string {
-		switch s {
-		case "GOEMON_TARGET_FILE":
-			return file
-		case "GOEMON_TARGET_BASE":
-			return filepath.Base(file)
-		case "GOEMON_TARGET_DIR":
-			return filepath.ToSlash(filepath.Dir(file))
-		case "GOEMON_TARGET_EXT":
-			return filepath.Ext(file)
-		case "GOEMON_TARGET_NAME":
-			fn := filepath.Base(file)
-			ext := filepath.Ext(file)
-			return fn[:len(fn)-len(ext)]
-		}
-		return os.Getenv(s)
-	})
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/c", command)
-	} else {
-		cmd = exec.Command("sh", "-c", command)
+func (g *Goemon) internalCommand(t *task, command, file string) bool {
+	command = t.scope(command)
+	ctx := &commands.Context{
+		File:    file,
+		Dir:     t.dir,
+		Stdout:  t.stdout(),
+		Stderr:  t.stderr(),
+		Logger:  g.Logger,
+		Allowed: g.allowedArgs,
 	}
-	g.Logger.Println("executing", command)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	err := cmd.Run()
-	if err != nil {
+	if err := g.Commands.Run(ctx, command); err != nil {
 		g.Logger.Println(err)
 		return false
 	}
 	return true
 }
 
-func (g *Goemon) minify(name string) bool {
-	if strings.HasSuffix(filepath.Base(name), ".min.") {
-		return true // ignore
+// allowed return true if command is allowed by AllowedCommands. All
+// commands are allowed when AllowedCommands is empty. Commands which contain
+// special characters of shell are not allowed because they can run other
+// commands.
+func (g *Goemon) allowed(command string) bool {
+	if len(g.AllowedCommands) == 0 {
+		return true
+	}
+	if strings.ContainsAny(command, ";&|`$<>()\\\n\r") {
+		return false
+	}
+	return g.allowedArgs(strings.Fields(command))
+}
+
+// allowedArgs return true if the command executed without shell is allowed
+// by AllowedCommands. Words of the command must be same as one of the list.
+// "*" at the end of the list matches any arguments.
+func (g *Goemon) allowedArgs(args []string) bool {
+	if len(g.AllowedCommands) == 0 {
+		return true
+	}
+	for _, a := range g.AllowedCommands {
+		words := strings.Fields(a)
+		if n := len(words); n > 0 && words[n-1] == "*" {
+			if len(args) >= n-1 && reflect.DeepEqual(words[:n-1], args[:n-1]) {
+				return true
+			}
+		} else if reflect.DeepEqual(words, args) {
+			return true
+		}
+	}
+	return false
+}
+
+// command return exec.Cmd which run name with args if it is allowed
+func (g *Goemon) command(name string, args ...string) (*exec.Cmd, error) {
+	if !g.allowedArgs(append([]string{name}, args...)) {
+		return nil, fmt.Errorf("command is not allowed: %v %v", name, strings.Join(args, " "))
+	}
+	return exec.Command(name, args...), nil
+}
+
+func (g *Goemon) externalCommand(t *task, command string) bool {
+	if !g.allowed(command) {
+		g.Logger.Println("command is not allowed:", command)
+		return false
 	}
-	ext := filepath.Ext(name)
-	if ext == "" {
-		return true // ignore
+	g.Logger.Println("executing", command)
+	cmd := runner.Shell(command)
+	cmd.Dir = t.dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = t.stdout()
+	cmd.Stderr = t.stderr()
+	if t.maxOutput > 0 {
+		cmd.Stdout, cmd.Stderr = runner.NewLimitWriters(cmd.Stdout, cmd.Stderr, t.maxOutput)
 	}
-	in, err := os.Open(name)
-	if err != nil {
+	if err := runner.Run(cmd, t.timeout); err != nil {
 		g.Logger.Println(err)
 		return false
 	}
-	defer in.Close()
+	return true
+}
 
-	switch ext {
-	case ".js":
+func (g *Goemon) spawn() error {
+	args := g.Args
+	if target, _ := g.debugTarget.Load().(string); target != "" && g.conf.Debug {
+		args = g.debugArgs(target)
+		g.Logger.Println("debugging", args)
+	}
+	g.proc = runner.NewProcess(args, g.Logger)
+	g.proc.OnStart = func() {
+		atomic.StoreInt32(&g.running, 1)
+		g.notify("READY=1")
+	}
+	defer atomic.StoreInt32(&g.running, 0)
+	return g.proc.Run()
+}
 
-		buf, err := jsmin.Minify(in)
-		if err != nil {
-			g.Logger.Println(err)
-			return false
-		}
-		err = ioutil.WriteFile(name[:len(name)-len(ext)]+".min.js", buf.Bytes(), 0644)
-		if err != nil {
-			g.Logger.Println(err)
-			return false
-		}
-		return true
-	case ".css":
-		out, err := os.Create(name[:len(name)-len(ext)] + ".min.css")
-		if err != nil {
-			g.Logger.Println(err)
-			return false
-		}
-		m := minify.New()
-		m.AddFunc("text/css", css.Minify)
-		if err := m.Minify("text/css", out, in); err != nil {
-			g.Logger.Println(err)
-			return false
+// terminate send sig to the command and processes. They are restarted
+// after terminated.
+func (g *Goemon) terminate(sig os.Signal) error {
+	var err error
+	for _, p := range g.procs {
+		if perr := p.terminate(sig); perr != nil {
+			err = perr
 		}
-		return true
 	}
-	return false
+	if g.proc == nil {
+		return err
+	}
+	if perr := g.proc.Terminate(sig); perr != nil {
+		err = perr
+	}
+	return err
 }
 
 func (g *Goemon) livereload() error {
-	g.lrs = livereload.New("goemon")
-	defer g.lrs.Close()
+	if err := g.writeToken(); err != nil {
+		g.Logger.Println("failed to write token of control:", err)
+	}
+	g.reloader = reload.New()
+	defer g.reloader.Close()
+	g.reloader.Handle("/goemon/events", g.webhook)
+	g.reloader.Handle("/goemon/ctl/", g.control())
+	return g.reloader.ListenAndServe(g.liveReloadAddr())
+}
+
+// liveReloadAddr return address of the livereload server
+func (g *Goemon) liveReloadAddr() string {
 	addr := g.conf.LiveReload
 	if addr == "" {
 		addr = os.Getenv("GOEMON_LIVERELOAD_ADDR")
 	}
 	if addr == "" {
-		addr = ":35730"
+		// other machines can connect with livereload: :35730
+		addr = "127.0.0.1:35730"
 	}
-	var err error
-	g.lrc, err = net.Listen("tcp", addr)
-	if err != nil {
-		return err
+	return addr
+}
+
+// startReloaders start livereload servers for tasks which have own address,
+// and stop servers which are no longer used.
+func (g *Goemon) startReloaders() {
+	g.reloadMutex.Lock()
+	defer g.reloadMutex.Unlock()
+	if g.reloaders == nil {
+		g.reloaders = map[string]*reload.Server{}
 	}
-	defer g.lrc.Close()
-	mux := http.NewServeMux()
-	mux.HandleFunc("/livereload.js", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/javascript")
-		_, err := w.Write([]byte(liveReloadScript))
-		if err != nil {
-			g.lrc.Close()
+	used := map[string]bool{}
+	for _, t := range g.conf.Tasks {
+		addr := t.LiveReload
+		if addr == "" || addr == g.conf.LiveReload || used[addr] {
+			continue
 		}
-	})
-	mux.Handle("/livereload", g.lrs)
-	return http.Serve(g.lrc, mux)
+		used[addr] = true
+		if _, ok := g.reloaders[addr]; ok {
+			continue
+		}
+		s := reload.New()
+		g.reloaders[addr] = s
+		go func(addr string) {
+			g.Logger.Println("starting livereload on", addr)
+			if err := s.ListenAndServe(addr); err != nil {
+				g.Logger.Println(err)
+			}
+		}(addr)
+	}
+	for addr, s := range g.reloaders {
+		if !used[addr] {
+			s.Close()
+			delete(g.reloaders, addr)
+		}
+	}
+}
+
+// reloaderFor return reloader for tasks in dir
+func (g *Goemon) reloaderFor(dir string) reload.Reloader {
+	g.reloadMutex.Lock()
+	defer g.reloadMutex.Unlock()
+	for _, t := range g.conf.Tasks {
+		if t.dir != dir || t.LiveReload == "" {
+			continue
+		}
+		if s, ok := g.reloaders[t.LiveReload]; ok {
+			return s
+		}
+	}
+	if g.Reloader != nil {
+		return g.Reloader
+	}
+	if g.reloader == nil {
+		return nil
+	}
+	return g.reloader
 }
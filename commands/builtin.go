@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/omeid/jsmin"
+	"github.com/tdewolff/minify"
+	"github.com/tdewolff/minify/css"
+)
+
+func sleep(ctx *Context, args ...string) error {
+	for _, s := range args {
+		si, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse argument for :sleep command: %v", err)
+		}
+		ctx.Logger.Println("sleeping", s+"ms")
+		time.Sleep(time.Duration(si) * time.Millisecond)
+	}
+	return nil
+}
+
+func fizzbuzz(ctx *Context, args ...string) error {
+	for _, s := range args {
+		si, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse argument for :fizzbuzz command: %v", err)
+		}
+		for i := int64(1); i <= si; i++ {
+			switch {
+			case i%15 == 0:
+				ctx.Logger.Println("FizzBuzz")
+			case i%3 == 0:
+				ctx.Logger.Println("Fizz")
+			case i%5 == 0:
+				ctx.Logger.Println("Buzz")
+			default:
+				ctx.Logger.Println(i)
+			}
+		}
+	}
+	return nil
+}
+
+func minifyCommand(ctx *Context, args ...string) error {
+	if ctx.Stdin != nil {
+		ext := filepath.Ext(ctx.File)
+		if len(args) > 0 {
+			ext = args[0]
+		}
+		return Minify(ctx.Stdout, ctx.Stdin, ext)
+	}
+	return MinifyFile(ctx.File)
+}
+
+// Minify minify js or css read from r into w. ext is ".js" or ".css".
+func Minify(w io.Writer, r io.Reader, ext string) error {
+	switch strings.TrimPrefix(ext, ".") {
+	case "js":
+		buf, err := jsmin.Minify(r)
+		if err != nil {
+			return err
+		}
+		_, err = buf.WriteTo(w)
+		return err
+	case "css":
+		m := minify.New()
+		m.AddFunc("text/css", css.Minify)
+		return m.Minify("text/css", w, r)
+	}
+	return fmt.Errorf("can't minify %q", ext)
+}
+
+// MinifyFile minify js or css file name into file which has ".min" before
+// the extension. For example, app.js is minified into app.min.js.
+func MinifyFile(name string) error {
+	if strings.HasSuffix(filepath.Base(name), ".min.") {
+		return nil // ignore
+	}
+	ext := filepath.Ext(name)
+	if ext == "" {
+		return nil // ignore
+	}
+	if ext != ".js" && ext != ".css" {
+		return fmt.Errorf("can't minify %q", name)
+	}
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(name[:len(name)-len(ext)] + ".min" + ext)
+	if err != nil {
+		return err
+	}
+	err = Minify(out, in, ext)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func read(ctx *Context, args ...string) error {
+	name := ctx.File
+	if len(args) > 0 {
+		name = ctx.Path(args[0])
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(ctx.Stdout, f)
+	return err
+}
+
+func write(ctx *Context, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(":write requires file name")
+	}
+	if ctx.Stdin == nil {
+		return fmt.Errorf(":write can be used only in pipeline")
+	}
+	f, err := os.Create(ctx.Path(args[0]))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, ctx.Stdin)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func gzipCommand(ctx *Context, args ...string) error {
+	if ctx.Stdin == nil {
+		return fmt.Errorf(":gzip can be used only in pipeline")
+	}
+	gw := gzip.NewWriter(ctx.Stdout)
+	if _, err := io.Copy(gw, ctx.Stdin); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// httpTimeout is timeout of :http command
+var httpTimeout = 30 * time.Second
+
+func httpCommand(ctx *Context, args ...string) error {
+	if len(args) != 2 {
+		return fmt.Errorf(":http requires method and url")
+	}
+	method := strings.ToUpper(args[0])
+	switch method {
+	case "GET", "POST":
+	default:
+		return fmt.Errorf(":http can't request with %q", args[0])
+	}
+	var body io.Reader
+	if ctx.Stdin != nil && method == "POST" {
+		body = ctx.Stdin
+	}
+	req, err := http.NewRequest(method, args[1], body)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: httpTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	out := ioutil.Discard
+	if ctx.Stdin != nil {
+		out = ctx.Stdout
+	}
+	if _, err = io.Copy(out, resp.Body); err != nil {
+		return err
+	}
+	ctx.Logger.Println(method, args[1], resp.Status)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf(":http %s %s: %s", method, args[1], resp.Status)
+	}
+	return nil
+}
+
+func touch(ctx *Context, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(":touch requires file name")
+	}
+	now := time.Now()
+	for _, arg := range args {
+		name := ctx.Path(arg)
+		if err := os.Chtimes(name, now, now); err == nil {
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		if err = f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mkdir(ctx *Context, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(":mkdir requires directory name")
+	}
+	for _, arg := range args {
+		if err := os.MkdirAll(ctx.Path(arg), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
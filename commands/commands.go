@@ -0,0 +1,110 @@
+// Package commands implements internal commands of goemon like :minify.
+// Commands can be used without watching files.
+package commands
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var commandRe = regexp.MustCompile(`^\s*(:[a-z]+!?)(?:\s+(\S+))*$`)
+
+// Context is environment to run commands
+type Context struct {
+	// File is the target file which triggered the command
+	File string
+	// Dir is working directory of the command. Relative file names in
+	// arguments are resolved from Dir.
+	Dir string
+	// Stdin is input of the command. If Stdin is not nil, the command is a
+	// part of pipeline. It should read input from Stdin and write output
+	// into Stdout instead of files.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Logger *log.Logger
+	// Allowed return false if the external command must not be executed.
+	// All commands are allowed if Allowed is nil.
+	Allowed func(args []string) bool
+}
+
+// Path return name resolved from Dir
+func (ctx *Context) Path(name string) string {
+	if ctx.Dir == "" || filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(ctx.Dir, name)
+}
+
+// Command is internal command
+type Command interface {
+	Run(ctx *Context, args ...string) error
+}
+
+// CommandFunc is adapter to use functions as Command
+type CommandFunc func(ctx *Context, args ...string) error
+
+// Run call f(ctx, args...)
+func (f CommandFunc) Run(ctx *Context, args ...string) error {
+	return f(ctx, args...)
+}
+
+// Set is set of commands keyed by name like ":minify"
+type Set map[string]Command
+
+// Builtin return new Set which contains builtin commands
+func Builtin() Set {
+	return Set{
+		":sleep":    CommandFunc(sleep),
+		":fizzbuzz": CommandFunc(fizzbuzz),
+		":minify":   CommandFunc(minifyCommand),
+		":read":     CommandFunc(read),
+		":write":    CommandFunc(write),
+		":gzip":     CommandFunc(gzipCommand),
+		":touch":    CommandFunc(touch),
+		":mkdir":    CommandFunc(mkdir),
+		":http":     CommandFunc(httpCommand),
+		":make":     CommandFunc(makeCommand),
+		":task":     CommandFunc(taskCommand),
+		":npm":      CommandFunc(npmCommand),
+	}
+}
+
+// IsCommand return true if s is internal command
+func IsCommand(s string) bool {
+	return commandRe.MatchString(s)
+}
+
+// Parse split s into name and arguments of the command
+func Parse(s string) (string, []string) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return fields[0], fields[1:]
+}
+
+// Run parse s and run the command
+func (set Set) Run(ctx *Context, s string) error {
+	name, args := Parse(s)
+	c, ok := set[name]
+	if !ok {
+		return fmt.Errorf("unknown command: %v", name)
+	}
+	if ctx.Stdout == nil {
+		ctx.Stdout = os.Stdout
+	}
+	if ctx.Stderr == nil {
+		ctx.Stderr = os.Stderr
+	}
+	if ctx.Logger == nil {
+		ctx.Logger = log.New(ioutil.Discard, "", 0)
+	}
+	return c.Run(ctx, args...)
+}
@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJsmin(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	f := filepath.Join(dir, "foo.js")
+	if MinifyFile(f) == nil {
+		t.Fatal("Should not be succeeded")
+	}
+	_, err = os.Stat(filepath.Join(dir, "foo.min.js"))
+	if err == nil {
+		t.Fatalf("Should be fail for non-exists file: %v", err)
+	}
+	if !os.IsNotExist(err) {
+		t.Fatalf("Should be fail for non-exists file: %v", err)
+	}
+	err = ioutil.WriteFile(f, []byte(``), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = MinifyFile(f); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	_, err = os.Stat(filepath.Join(dir, "foo.min.js"))
+	if err != nil {
+		t.Fatal(t)
+	}
+}
+
+func TestParse(t *testing.T) {
+	name, args := Parse(" :confirm  Drop database? ")
+	if name != ":confirm" {
+		t.Fatal("Should be :confirm:", name)
+	}
+	if !reflect.DeepEqual(args, []string{"Drop", "database?"}) {
+		t.Fatal("Should be arguments:", args)
+	}
+	if !IsCommand(":restart!") || IsCommand("go build") {
+		t.Fatal("Should detect internal commands")
+	}
+}
+
+func TestRun(t *testing.T) {
+	set := Builtin()
+
+	var out bytes.Buffer
+	ctx := &Context{File: "app.css", Stdin: strings.NewReader("a  {  color : red ; }"), Stdout: &out}
+	if err := set.Run(ctx, ":minify"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if out.String() != "a{color:red}" {
+		t.Fatalf("Should be minified but got %q", out.String())
+	}
+
+	if err := set.Run(&Context{}, ":gzip"); err == nil {
+		t.Fatal("Should not be succeeded out of pipeline")
+	}
+	if err := set.Run(&Context{}, ":foo"); err == nil {
+		t.Fatal("Should not be succeeded for unknown command")
+	}
+	if err := set.Run(&Context{}, ":sleep foo"); err == nil {
+		t.Fatal("Should not be succeeded for invalid argument")
+	}
+}
+
+func TestTouchMkdir(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	set := Builtin()
+	ctx := &Context{Dir: dir}
+	if err := set.Run(ctx, ":mkdir out/css out/js"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	for _, name := range []string{"out/css", "out/js"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil || !fi.IsDir() {
+			t.Fatal("Should be created:", name)
+		}
+	}
+
+	sentinel := filepath.Join(dir, "out", ".done")
+	if err := set.Run(ctx, ":touch out/.done"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(sentinel, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := set.Run(ctx, ":touch out/.done"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	fi, err := os.Stat(sentinel)
+	if err != nil {
+		t.Fatal("Should be created", err)
+	}
+	if !fi.ModTime().After(old) {
+		t.Fatal("Should be updated modification time:", fi.ModTime())
+	}
+
+	if err := set.Run(ctx, ":touch"); err == nil {
+		t.Fatal("Should not be succeeded without file name")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		got = append(got, r.Method+" "+r.URL.Path+" "+string(b))
+		if r.URL.Path == "/fail" {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	set := Builtin()
+	if err := set.Run(&Context{}, ":http GET "+ts.URL+"/reload"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	var out bytes.Buffer
+	ctx := &Context{Stdin: strings.NewReader("purge"), Stdout: &out}
+	if err := set.Run(ctx, ":http post "+ts.URL+"/purge"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if out.String() != "ok" {
+		t.Fatalf("Should be output response but got %q", out.String())
+	}
+	if err := set.Run(&Context{}, ":http POST "+ts.URL+"/fail"); err == nil {
+		t.Fatal("Should not be succeeded for error status")
+	}
+	if err := set.Run(&Context{}, ":http DELETE "+ts.URL); err == nil {
+		t.Fatal("Should not be succeeded for unsupported method")
+	}
+
+	expected := []string{"GET /reload ", "POST /purge purge", "POST /fail "}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Should be %q but got %q", expected, got)
+	}
+}
+
+func TestMake(t *testing.T) {
+	if _, err := exec.LookPath("make"); err != nil {
+		t.Skip("make is not installed")
+	}
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sub := filepath.Join(dir, "web")
+	os.Mkdir(sub, 0755)
+	ioutil.WriteFile(filepath.Join(dir, "Makefile"), []byte("VERSION := 1\n\nbuild test:\n\t@echo root $@\n\nfail:\n\t@exit 3\n"), 0644)
+	ioutil.WriteFile(filepath.Join(sub, "Makefile"), []byte("build:\n\t@echo web $@\n"), 0644)
+
+	set := Builtin()
+	var out bytes.Buffer
+	ctx := &Context{File: filepath.Join(sub, "app.js"), Dir: dir, Stdout: &out}
+	if err := set.Run(ctx, ":make build"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	// test is not defined in web/Makefile
+	if err := set.Run(ctx, ":make test"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if out.String() != "web build\nroot test\n" {
+		t.Fatalf("Should run the nearest Makefile which defines target but got %q", out.String())
+	}
+	if err := set.Run(ctx, ":make fail"); err == nil || !strings.Contains(err.Error(), "exit status") {
+		t.Fatal("Should propagate failure:", err)
+	}
+
+	if err := set.Run(&Context{Dir: sub}, ":task build"); err == nil {
+		t.Fatal("Should not be succeeded without Taskfile")
+	}
+	targets := taskTargets([]byte("version: '3'\ntasks:\n  build:\n    cmds: [go build]\n  lint: {}\n"))
+	if !reflect.DeepEqual(targets, map[string]bool{"build": true, "lint": true}) {
+		t.Fatal("Should parse tasks:", targets)
+	}
+	targets = makeTargets([]byte("A := b\nall: a b\n\techo a:b\n.PHONY: all\n"))
+	if !reflect.DeepEqual(targets, map[string]bool{"all": true, ".PHONY": true}) {
+		t.Fatal("Should parse targets:", targets)
+	}
+}
+
+func TestNpm(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	web := filepath.Join(dir, "web")
+	os.MkdirAll(filepath.Join(web, "src"), 0755)
+	ioutil.WriteFile(filepath.Join(web, "package.json"), []byte(`{"scripts":{"build":"echo built"}}`), 0644)
+
+	ctx := &Context{File: filepath.Join(web, "src", "app.js"), Dir: dir}
+	found, err := targetDir(ctx, []string{"package.json"}, npmScripts, []string{"build"})
+	if err != nil || found != web {
+		t.Fatalf("Should find %q but got %q: %v", web, found, err)
+	}
+	for _, lockfile := range lockfiles {
+		// lockfile of workspace root
+		ioutil.WriteFile(filepath.Join(dir, lockfile.name), nil, 0644)
+		if manager, err := packageManager(ctx, web); err != nil || manager != lockfile.manager {
+			t.Fatalf("Should be %q but got %q: %v", lockfile.manager, manager, err)
+		}
+		os.Remove(filepath.Join(dir, lockfile.name))
+	}
+	if manager, _ := packageManager(ctx, web); manager != "npm" {
+		t.Fatal("Should be npm without lockfile but got", manager)
+	}
+
+	if _, err := exec.LookPath("npm"); err != nil {
+		t.Skip("npm is not installed")
+	}
+	set := Builtin()
+	var out bytes.Buffer
+	ctx.Stdout = &out
+	if err := set.Run(ctx, ":npm build"); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if !strings.Contains(out.String(), "built") {
+		t.Fatalf("Should run script but got %q", out.String())
+	}
+	if err := set.Run(ctx, ":npm missing"); err == nil {
+		t.Fatal("Should not be succeeded for missing script")
+	}
+}
@@ -0,0 +1,141 @@
+package commands
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// makefiles and taskfiles are names of files searched by :make and :task
+var (
+	makefiles = []string{"GNUmakefile", "makefile", "Makefile"}
+	taskfiles = []string{"Taskfile.yml", "taskfile.yml", "Taskfile.yaml", "taskfile.yaml"}
+)
+
+// makeTargetRe match rules like "build test: deps". Variables like "A := b"
+// are not matched.
+var makeTargetRe = regexp.MustCompile(`(?m)^([^\s#:=][^:=]*?)\s*::?(?:[^=]|$)`)
+
+func makeCommand(ctx *Context, args ...string) error {
+	return runTarget(ctx, "make", makefiles, makeTargets, args)
+}
+
+func taskCommand(ctx *Context, args ...string) error {
+	return runTarget(ctx, "task", taskfiles, taskTargets, args)
+}
+
+// runTarget run targets with the nearest file in names which defines them
+func runTarget(ctx *Context, name string, names []string, targets func([]byte) map[string]bool, args []string) error {
+	dir, err := targetDir(ctx, names, targets, args)
+	if err != nil {
+		return fmt.Errorf(":%s %w", name, err)
+	}
+	return runIn(ctx, dir, name, args)
+}
+
+// searchDirs return directories from the directory of the changed file up to
+// Dir
+func searchDirs(ctx *Context) ([]string, error) {
+	root, err := filepath.Abs(ctx.Path("."))
+	if err != nil {
+		return nil, err
+	}
+	dir := root
+	if ctx.File != "" {
+		if file, err := filepath.Abs(ctx.Path(ctx.File)); err == nil {
+			if rel, err := filepath.Rel(root, file); err == nil && !strings.HasPrefix(rel, "..") {
+				dir = filepath.Dir(file)
+			}
+		}
+	}
+	var dirs []string
+	for {
+		dirs = append(dirs, dir)
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return dirs, nil
+}
+
+// targetDir return the nearest directory which has the file in names
+// defining targets in args. Arguments like "-k" or "A=b" are not targets.
+func targetDir(ctx *Context, names []string, targets func([]byte) map[string]bool, args []string) (string, error) {
+	dirs, err := searchDirs(ctx)
+	if err != nil {
+		return "", err
+	}
+	found := ""
+	for _, dir := range dirs {
+		for _, n := range names {
+			b, err := ioutil.ReadFile(filepath.Join(dir, n))
+			if err != nil {
+				continue
+			}
+			if found == "" {
+				found = dir
+			}
+			defined := targets(b)
+			ok := true
+			for _, arg := range args {
+				if !strings.HasPrefix(arg, "-") && !strings.Contains(arg, "=") && !defined[arg] {
+					ok = false
+				}
+			}
+			if ok {
+				return dir, nil
+			}
+		}
+	}
+	if found == "" {
+		return "", fmt.Errorf("can't find %s", names[len(names)-1])
+	}
+	// targets may be defined by included files or implicit rules
+	return found, nil
+}
+
+func runIn(ctx *Context, dir string, name string, args []string) error {
+	if ctx.Allowed != nil && !ctx.Allowed(append([]string{name}, args...)) {
+		return fmt.Errorf("command is not allowed: %s %s", name, strings.Join(args, " "))
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	cmd.Stdin = ctx.Stdin
+	cmd.Stdout = ctx.Stdout
+	cmd.Stderr = ctx.Stderr
+	ctx.Logger.Println("executing", name, strings.Join(args, " "), "in", dir)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf(":%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+func makeTargets(b []byte) map[string]bool {
+	targets := map[string]bool{}
+	for _, m := range makeTargetRe.FindAllSubmatch(b, -1) {
+		for _, target := range strings.Fields(string(m[1])) {
+			targets[target] = true
+		}
+	}
+	return targets
+}
+
+func taskTargets(b []byte) map[string]bool {
+	var taskfile struct {
+		Tasks map[string]interface{} `yaml:"tasks"`
+	}
+	targets := map[string]bool{}
+	if err := yaml.Unmarshal(b, &taskfile); err != nil {
+		return targets
+	}
+	for name := range taskfile.Tasks {
+		targets[name] = true
+	}
+	return targets
+}
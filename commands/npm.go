@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockfiles map lockfiles to package managers which create them
+var lockfiles = []struct {
+	name    string
+	manager string
+}{
+	{"pnpm-lock.yaml", "pnpm"},
+	{"yarn.lock", "yarn"},
+	{"package-lock.json", "npm"},
+}
+
+func npmCommand(ctx *Context, args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf(":npm requires script name")
+	}
+	dir, err := targetDir(ctx, []string{"package.json"}, npmScripts, args[:1])
+	if err != nil {
+		return fmt.Errorf(":npm %w", err)
+	}
+	manager, err := packageManager(ctx, dir)
+	if err != nil {
+		return err
+	}
+	run := []string{"run", args[0]}
+	if len(args) > 1 {
+		if manager == "npm" {
+			run = append(run, "--")
+		}
+		run = append(run, args[1:]...)
+	}
+	return runIn(ctx, dir, manager, run)
+}
+
+// packageManager detect the package manager from the lockfile in dir or its
+// parents like workspaces. npm is used when no lockfile is found.
+func packageManager(ctx *Context, dir string) (string, error) {
+	dirs, err := searchDirs(&Context{File: filepath.Join(dir, "package.json"), Dir: ctx.Dir})
+	if err != nil {
+		return "", err
+	}
+	for _, d := range dirs {
+		for _, lockfile := range lockfiles {
+			if _, err := os.Stat(filepath.Join(d, lockfile.name)); err == nil {
+				return lockfile.manager, nil
+			}
+		}
+	}
+	return "npm", nil
+}
+
+func npmScripts(b []byte) map[string]bool {
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	targets := map[string]bool{}
+	if err := json.Unmarshal(b, &pkg); err != nil {
+		return targets
+	}
+	for name := range pkg.Scripts {
+		targets[name] = true
+	}
+	return targets
+}
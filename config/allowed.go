@@ -0,0 +1,51 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AllowedCommandsPath return the path of the file which list allowed
+// commands. It is in the directory of the user, so files in repositories
+// can't change it.
+func AllowedCommandsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "goemon", "allowed_commands"), nil
+}
+
+// LoadAllowedCommands read allowed commands from AllowedCommandsPath. Each
+// line is one command, and lines starting with # are comments. It return
+// nil if the file doesn't exist.
+func LoadAllowedCommands() ([]string, error) {
+	fn, err := AllowedCommandsPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadFile(fn)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = CheckOwner(fn); err != nil {
+		return nil, err
+	}
+	var commands []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		commands = append(commands, line)
+	}
+	return commands, scanner.Err()
+}
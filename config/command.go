@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// knownOS is GOOS which can be keys of the command. It is used to find typos.
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true,
+	"freebsd": true, "illumos": true, "ios": true, "js": true, "linux": true,
+	"netbsd": true, "openbsd": true, "plan9": true, "solaris": true,
+	"windows": true, "default": true,
+}
+
+// Commands is the list of commands. Each command can be a map keyed by GOOS
+// like {linux: make, windows: nmake}, and the command for the current OS is
+// selected. "default" is used for other OS. The command is skipped if there
+// is nothing for the current OS.
+type Commands []string
+
+// UnmarshalYAML decode commands which can be maps keyed by GOOS
+func (c *Commands) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var items []command
+	if err := unmarshal(&items); err != nil {
+		return err
+	}
+	if items == nil {
+		*c = nil
+		return nil
+	}
+	*c = Commands{}
+	for _, item := range items {
+		if item.ok {
+			*c = append(*c, item.s)
+		}
+	}
+	return nil
+}
+
+type command struct {
+	s  string
+	ok bool
+}
+
+func (c *command) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&c.s); err == nil {
+		c.ok = true
+		return nil
+	}
+	var m map[string]string
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for goos := range m {
+		if !knownOS[goos] {
+			return fmt.Errorf("unknown GOOS %q in command", goos)
+		}
+	}
+	if c.s, c.ok = m[runtime.GOOS]; !c.ok {
+		c.s, c.ok = m["default"]
+	}
+	return nil
+}
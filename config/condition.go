@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// Condition is the condition of enabled_if which is evaluated when the
+// configuration is loaded. All of set fields should be satisfied. OS is
+// GOOS like "linux,darwin", Env is the environment variable which is set
+// like "CI" or "CI=true", and File is the file which exists. Each of them
+// can be negated with "!" like "!windows".
+type Condition struct {
+	OS   string `yaml:"os"`
+	Env  string `yaml:"env"`
+	File string `yaml:"file"`
+}
+
+// UnmarshalYAML decode the condition. Unquoted "!windows" is a tag of YAML
+// and decoded as empty string silently, so empty values are errors.
+func (c *Condition) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var m map[string]interface{}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return fmt.Errorf("enabled_if: %s should not be empty, quote negation like '!%s'", k, k)
+		}
+		switch k {
+		case "os":
+			c.OS = s
+		case "env":
+			c.Env = s
+		case "file":
+			c.File = s
+		default:
+			return fmt.Errorf("enabled_if: unknown condition %q", k)
+		}
+	}
+	return nil
+}
+
+// Enabled return true if c is nil or satisfied. Relative file is resolved
+// from the current directory.
+func (c *Condition) Enabled() bool {
+	if c == nil {
+		return true
+	}
+	if c.OS != "" {
+		name, not := negate(c.OS)
+		found := false
+		for _, goos := range strings.Split(name, ",") {
+			if strings.TrimSpace(goos) == runtime.GOOS {
+				found = true
+			}
+		}
+		if found == not {
+			return false
+		}
+	}
+	if c.Env != "" {
+		name, not := negate(c.Env)
+		var ok bool
+		if i := strings.Index(name, "="); i >= 0 {
+			ok = os.Getenv(name[:i]) == name[i+1:]
+		} else {
+			ok = os.Getenv(name) != ""
+		}
+		if ok == not {
+			return false
+		}
+	}
+	if c.File != "" {
+		name, not := negate(c.File)
+		_, err := os.Stat(name)
+		if (err == nil) == not {
+			return false
+		}
+	}
+	return true
+}
+
+// String return the condition like "os=!windows env=CI"
+func (c *Condition) String() string {
+	var s []string
+	if c.OS != "" {
+		s = append(s, "os="+c.OS)
+	}
+	if c.Env != "" {
+		s = append(s, "env="+c.Env)
+	}
+	if c.File != "" {
+		s = append(s, "file="+c.File)
+	}
+	return strings.Join(s, " ")
+}
+
+func negate(s string) (string, bool) {
+	if strings.HasPrefix(s, "!") {
+		return s[1:], true
+	}
+	return s, false
+}
@@ -0,0 +1,243 @@
+// Package config read the configuration file of goemon
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// ErrConfigNotFound is returned when the configuration file does not exist
+	ErrConfigNotFound = errors.New("configuration file not found")
+
+	// ErrConfigNotOwned is returned when the configuration file is not owned
+	// by the current user
+	ErrConfigNotOwned = errors.New("configuration file not owned by current user")
+)
+
+// Config is the configuration of goemon
+type Config struct {
+	Command         string
+	LiveReload      string   `yaml:"livereload"`
+	FirstMatch      bool     `yaml:"first_match"`
+	Webhook         bool     `yaml:"webhook"`
+	Sync            Sync     `yaml:"sync"`
+	Timeout         string   `yaml:"command_timeout"`
+	MaxOutput       string   `yaml:"max_output"`
+	ConfirmTimeout  string   `yaml:"confirm_timeout"`
+	Migrate         Migrate  `yaml:"migrate"`
+	ConfigOptional  bool     `yaml:"config_optional"`
+	Root            string   `yaml:"root"`
+	AllowedCommands []string `yaml:"allowed_commands"` // only to warn, see LoadAllowedCommands
+	DepsCommand     string   `yaml:"deps_command"`
+	DepsFiles       []string `yaml:"deps_files"`
+	Debug           bool     `yaml:"debug"`
+	DebugAddr       string   `yaml:"debug_addr"`
+	CoverageTTL     string   `yaml:"coverage_ttl"`
+	Tasks           []*Task  `yaml:"tasks"`
+
+	// EnabledIf disable the configuration when it is not satisfied. In
+	// documents other than the first, it is the default of the tasks.
+	EnabledIf *Condition `yaml:"enabled_if"`
+
+	// LatencyThreshold is duration like "2s". goemon warn when the task
+	// finished later than it since the event.
+	LatencyThreshold string `yaml:"latency_threshold"`
+
+	// NotifySummary send the summary line after bursts of events to systemd
+	// as STATUS=.
+	NotifySummary bool `yaml:"notify_summary"`
+
+	Processes map[string]*Process `yaml:"processes"`
+}
+
+// Process is the configuration of the long-running process. Restart is one
+// of "always", "on-failure" and "no", which decide whether the process is
+// restarted when it exited by itself. The process is started after processes
+// in DependsOn are ready.
+type Process struct {
+	Command   string   `yaml:"command"`
+	Restart   string   `yaml:"restart"`
+	DependsOn []string `yaml:"depends_on"`
+	Ready     *Probe   `yaml:"ready"`
+}
+
+// Probe is the readiness probe of the process. The process is ready when
+// the URL of HTTP returns 2xx, the address of TCP accepts connections, or
+// Command exits successfully. The process without probe is ready when it is
+// started.
+type Probe struct {
+	HTTP     string `yaml:"http"`
+	TCP      string `yaml:"tcp"`
+	Command  string `yaml:"command"`
+	Interval string `yaml:"interval"`
+}
+
+// Task is the configuration of the task
+type Task struct {
+	Match       string   `yaml:"match"`
+	Ignore      string   `yaml:"ignore"`
+	Commands    Commands `yaml:"commands"`
+	Ops         []string `yaml:"ops"`
+	Priority    int      `yaml:"priority"`
+	Exclusive   bool     `yaml:"exclusive"`
+	Timeout     string   `yaml:"command_timeout"`
+	MaxOutput   string   `yaml:"max_output"`
+	Cache       bool     `yaml:"cache"`
+	WatchURL    string   `yaml:"watch_url"`
+	WatchCmd    string   `yaml:"watch_cmd"`
+	Interval    string   `yaml:"watch_interval"`
+	Pipe        []string `yaml:"pipe"`
+	Root        string   `yaml:"root"`
+	LiveReload  string   `yaml:"livereload"`
+	MinInterval string   `yaml:"min_interval"`
+	OnSuccess   Commands `yaml:"on_success"`
+	OnFailure   Commands `yaml:"on_failure"`
+	Restart     []string `yaml:"restart"`
+
+	EnabledIf      *Condition      `yaml:"enabled_if"`
+	ProblemMatcher *ProblemMatcher `yaml:"problem_matcher"`
+}
+
+// Sync is the configuration of the two-way sync
+type Sync struct {
+	Dest   string `yaml:"dest"`
+	Ignore string `yaml:"ignore"`
+}
+
+// Migrate is the configuration of :migrate
+type Migrate struct {
+	Tool     string `yaml:"tool"`
+	Dir      string `yaml:"dir"`
+	Driver   string `yaml:"driver"`
+	Database string `yaml:"database"`
+}
+
+// Load read the configuration file. It retries a few times because editors
+// may remove the file before writing. The file must be owned by the current
+// user because commands in the file are executed.
+func Load(fn string) (*Config, error) {
+	return LoadVerified(fn, nil)
+}
+
+// LoadVerified is same as Load but verify the signature of the file with v.
+// The file which has valid signature doesn't need to be owned by the current
+// user. If v is nil, the signature is not verified.
+func LoadVerified(fn string, v Verifier) (*Config, error) {
+	var b []byte
+	var err error
+	for i := 0; i < 3; i++ {
+		b, err = ioutil.ReadFile(fn)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrConfigNotFound, fn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if v != nil {
+		err = v.Verify(fn, b)
+	} else {
+		err = CheckOwner(fn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	// root is relative to the file, not to the working directory
+	for _, t := range c.Tasks {
+		if t.Root != "" && !filepath.IsAbs(t.Root) {
+			t.Root = filepath.Join(filepath.Dir(fn), t.Root)
+		}
+	}
+	if c.Root != "" && !filepath.IsAbs(c.Root) {
+		c.Root = filepath.Join(filepath.Dir(fn), c.Root)
+	}
+	return c, nil
+}
+
+// Parse parse the configuration. The configuration can contain multiple
+// documents. Tasks of all documents are merged into the first document with
+// root and livereload of each document. Other settings are read from the
+// first document. Relative roots are not resolved, LoadVerified resolve them
+// from the directory of the file.
+func Parse(b []byte) (*Config, error) {
+	var c *Config
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	for {
+		doc := &Config{}
+		err := dec.Decode(doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range doc.Tasks {
+			if t.Root == "" {
+				t.Root = doc.Root
+			}
+			if t.LiveReload == "" && c != nil {
+				t.LiveReload = doc.LiveReload
+			}
+			if t.EnabledIf == nil && c != nil {
+				t.EnabledIf = doc.EnabledIf
+			}
+		}
+		if c == nil {
+			c = doc
+			continue
+		}
+		c.Tasks = append(c.Tasks, doc.Tasks...)
+	}
+	if c == nil {
+		c = &Config{}
+	}
+	if c.Tasks == nil {
+		c.Tasks = []*Task{}
+	}
+	return c, nil
+}
+
+// ParseSize parse size like "512", "100KB" or "1MB"
+func ParseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		unit   int64
+	}{
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSpace(s[:len(s)-len(u.suffix)])
+			unit = u.unit
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %v", err)
+	}
+	return n * unit, nil
+}
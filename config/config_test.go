@@ -0,0 +1,321 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse([]byte(`
+command: ./app
+tasks:
+- match: './assets/*.js'
+  commands:
+  - :livereload /static/{base}
+`))
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if c.Command != "./app" || len(c.Tasks) != 1 || c.Tasks[0].Match != "./assets/*.js" {
+		t.Fatal("Should be parsed:", c)
+	}
+
+	c, err = Parse([]byte(``))
+	if err != nil || c.Tasks == nil {
+		t.Fatal("Should be empty configuration", err)
+	}
+
+	if _, err = Parse([]byte(`asdfasdf`)); err == nil {
+		t.Fatal("Should not be succeeded")
+	}
+}
+
+func TestParseDocuments(t *testing.T) {
+	c, err := Parse([]byte(`
+livereload: :35730
+root: frontend
+tasks:
+- match: './src/*.js'
+---
+root: backend
+livereload: :35731
+tasks:
+- match: './*.go'
+- match: './*.tmpl'
+  root: templates
+`))
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if len(c.Tasks) != 3 {
+		t.Fatal("Should merge tasks of all documents:", len(c.Tasks))
+	}
+	expected := []struct {
+		root       string
+		livereload string
+	}{
+		{"frontend", ""},
+		{"backend", ":35731"},
+		{"templates", ":35731"},
+	}
+	for i, e := range expected {
+		if c.Tasks[i].Root != e.root || c.Tasks[i].LiveReload != e.livereload {
+			t.Fatalf("Should be %v but got %v %v", e, c.Tasks[i].Root, c.Tasks[i].LiveReload)
+		}
+	}
+	if c.LiveReload != ":35730" {
+		t.Fatal("Should use settings of the first document:", c.LiveReload)
+	}
+}
+
+func TestCondition(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv("GOEMON_TEST_ENV", "dev")
+	defer os.Unsetenv("GOEMON_TEST_ENV")
+
+	c, err := Parse([]byte(`
+enabled_if:
+  os: '!plan9'
+tasks:
+- match: './*.go'
+  enabled_if:
+    env: GOEMON_TEST_ENV=dev
+    file: ` + filepath.ToSlash(dir) + `
+- match: './*.js'
+  enabled_if:
+    os: ` + runtime.GOOS + `,plan9
+    env: '!GOEMON_TEST_ENV'
+---
+enabled_if:
+  file: '!` + filepath.ToSlash(dir) + `'
+tasks:
+- match: './*.css'
+`))
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if !c.EnabledIf.Enabled() {
+		t.Fatal("Should be enabled:", c.EnabledIf)
+	}
+	expected := []bool{true, false, false}
+	for i, e := range expected {
+		if got := c.Tasks[i].EnabledIf.Enabled(); got != e {
+			t.Fatalf("Should be %v for %s but got %v", e, c.Tasks[i].EnabledIf, got)
+		}
+	}
+	if _, err = Parse([]byte("enabled_if:\n  os: !windows\n")); err == nil {
+		t.Fatal("Should not be succeeded for unquoted negation")
+	}
+	if _, err = Parse([]byte("enabled_if:\n  arch: amd64\n")); err == nil {
+		t.Fatal("Should not be succeeded for unknown condition")
+	}
+}
+
+func TestCommandsPerOS(t *testing.T) {
+	c, err := Parse([]byte(`
+tasks:
+- match: './*.go'
+  commands:
+  - go build
+  - ` + runtime.GOOS + `: echo current
+    plan9: echo plan9
+  - plan9: echo plan9
+  - plan9: echo plan9
+    default: echo default
+  on_failure:
+  - plan9: echo plan9
+`))
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	expected := Commands{"go build", "echo current", "echo default"}
+	if !reflect.DeepEqual(c.Tasks[0].Commands, expected) {
+		t.Fatalf("Should be %q but got %q", expected, c.Tasks[0].Commands)
+	}
+	if c.Tasks[0].OnFailure == nil || len(c.Tasks[0].OnFailure) != 0 {
+		t.Fatalf("Should skip commands for other OS but got %q", c.Tasks[0].OnFailure)
+	}
+	if c.Tasks[0].OnSuccess != nil {
+		t.Fatal("Should be nil:", c.Tasks[0].OnSuccess)
+	}
+
+	if _, err = Parse([]byte("tasks:\n- commands:\n  - linx: make\n")); err == nil {
+		t.Fatal("Should not be succeeded for unknown GOOS")
+	}
+}
+
+func TestProblemMatcher(t *testing.T) {
+	c, err := Parse([]byte(`
+tasks:
+- match: './*.go'
+  problem_matcher: $go
+- match: './*.c'
+  problem_matcher:
+    regexp: '^(.*):(\d+): (.*)$'
+`))
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if !reflect.DeepEqual(*c.Tasks[0].ProblemMatcher, ProblemMatchers["$go"]) {
+		t.Fatal("Should be builtin matcher:", c.Tasks[0].ProblemMatcher)
+	}
+	expected := ProblemMatcher{Regexp: `^(.*):(\d+): (.*)$`, File: 1, Line: 2, Message: 3}
+	if !reflect.DeepEqual(*c.Tasks[1].ProblemMatcher, expected) {
+		t.Fatal("Should fill default indexes:", c.Tasks[1].ProblemMatcher)
+	}
+	if _, err = Parse([]byte("tasks:\n- problem_matcher: $foo\n")); err == nil {
+		t.Fatal("Should not be succeeded for unknown matcher")
+	}
+}
+
+func TestLoadNotFound(t *testing.T) {
+	_, err := Load(filepath.Join("testdata", "not-found.yml"))
+	if !errors.Is(err, ErrConfigNotFound) {
+		t.Fatal("Should be ErrConfigNotFound", err)
+	}
+}
+
+func TestLoadRoot(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "goemon.yml")
+	abs := filepath.Join(dir, "abs")
+	ioutil.WriteFile(fn, []byte(`
+root: frontend
+tasks:
+- match: './src/*.js'
+---
+tasks:
+- match: './*.go'
+  root: `+abs+`
+- match: './*.tmpl'
+`), 0644)
+	c, err := Load(fn)
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if c.Root != filepath.Join(dir, "frontend") {
+		t.Fatal("Should be relative to the file:", c.Root)
+	}
+	for i, expected := range []string{filepath.Join(dir, "frontend"), abs, ""} {
+		if c.Tasks[i].Root != expected {
+			t.Fatalf("Should be %q but got %q", expected, c.Tasks[i].Root)
+		}
+	}
+}
+
+func TestCheckOwner(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Getuid() != 0 {
+		t.Skip("chown is not available")
+	}
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "goemon.yml")
+	ioutil.WriteFile(fn, []byte(`command: ./app`), 0644)
+	if _, err = Load(fn); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if err = os.Chown(fn, 65534, 65534); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Load(fn); !errors.Is(err, ErrConfigNotOwned) {
+		t.Fatal("Should be ErrConfigNotOwned", err)
+	}
+}
+
+func TestLoadAllowedCommands(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("XDG_CONFIG_HOME is not used")
+	}
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+
+	if commands, err := LoadAllowedCommands(); err != nil || commands != nil {
+		t.Fatal("Should be nil without the file", commands, err)
+	}
+	os.Mkdir(filepath.Join(dir, "goemon"), 0700)
+	ioutil.WriteFile(filepath.Join(dir, "goemon", "allowed_commands"), []byte("# build\ngo build *\n\n  go vet  \n"), 0600)
+	commands, err := LoadAllowedCommands()
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if expected := []string{"go build *", "go vet"}; !reflect.DeepEqual(commands, expected) {
+		t.Fatalf("Should be %q but got %q", expected, commands)
+	}
+}
+
+func TestSSHSignature(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen is not found")
+	}
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key := filepath.Join(dir, "id_ed25519")
+	if err = exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-f", key).Run(); err != nil {
+		t.Fatal(err)
+	}
+	pub, err := ioutil.ReadFile(key + ".pub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers := filepath.Join(dir, "allowed_signers")
+	ioutil.WriteFile(signers, append([]byte("goemon@example.com "), pub...), 0644)
+
+	fn := filepath.Join(dir, "goemon.yml")
+	ioutil.WriteFile(fn, []byte(`command: ./app`), 0644)
+	if err = exec.Command("ssh-keygen", "-Y", "sign", "-f", key, "-n", "goemon", fn).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	v := &SSHSignature{AllowedSigners: signers}
+	c, err := LoadVerified(fn, v)
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if c.Command != "./app" {
+		t.Fatal("Should load the configuration:", c.Command)
+	}
+
+	ioutil.WriteFile(fn, []byte(`command: rm -rf /`), 0644)
+	if _, err = LoadVerified(fn, v); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatal("Should be ErrSignatureInvalid", err)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for s, n := range map[string]int64{"512": 512, "100KB": 100 << 10, "1 mb": 1 << 20} {
+		if v, err := ParseSize(s); err != nil || v != n {
+			t.Fatalf("Should be %v but got %v: %v", n, v, err)
+		}
+	}
+	if _, err := ParseSize("foo"); err == nil {
+		t.Fatal("Should not be succeeded")
+	}
+}
@@ -0,0 +1,26 @@
+// +build !windows
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// CheckOwner return ErrConfigNotOwned if the file is not owned by the
+// current user.
+func CheckOwner(fn string) error {
+	fi, err := os.Stat(fn)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	if int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%w: %s is owned by uid %d", ErrConfigNotOwned, fn, st.Uid)
+	}
+	return nil
+}
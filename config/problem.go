@@ -0,0 +1,56 @@
+package config
+
+import "fmt"
+
+// ProblemMatcher parse output of commands into problems like problem
+// matchers of VS Code. Regexp is matched with each line, and other fields
+// are indexes of groups. Column and Severity are optional. Names of builtin
+// matchers like "$go" can be used instead.
+type ProblemMatcher struct {
+	Regexp   string `yaml:"regexp"`
+	File     int    `yaml:"file"`
+	Line     int    `yaml:"line"`
+	Column   int    `yaml:"column"`
+	Severity int    `yaml:"severity"`
+	Message  int    `yaml:"message"`
+}
+
+// ProblemMatchers is builtin problem matchers
+var ProblemMatchers = map[string]ProblemMatcher{
+	// go build, go vet, staticcheck and golangci-lint
+	"$go": {Regexp: `^\s*([^\s:]+\.go):(\d+)(?::(\d+))?: (.*)$`, File: 1, Line: 2, Column: 3, Message: 4},
+	// gcc and clang
+	"$gcc": {Regexp: `^(.+?):(\d+):(\d+):\s+(?:fatal\s+)?(warning|error):\s+(.*)$`, File: 1, Line: 2, Column: 3, Severity: 4, Message: 5},
+	// tsc
+	"$tsc": {Regexp: `^(.+?)\((\d+),(\d+)\): (error|warning) (.*)$`, File: 1, Line: 2, Column: 3, Severity: 4, Message: 5},
+}
+
+// UnmarshalYAML decode the problem matcher or the name of builtin one
+func (m *ProblemMatcher) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		builtin, ok := ProblemMatchers[name]
+		if !ok {
+			return fmt.Errorf("unknown problem_matcher: %v", name)
+		}
+		*m = builtin
+		return nil
+	}
+	type plain ProblemMatcher
+	if err := unmarshal((*plain)(m)); err != nil {
+		return err
+	}
+	if m.Regexp == "" {
+		return fmt.Errorf("problem_matcher requires regexp")
+	}
+	if m.File == 0 {
+		m.File = 1
+	}
+	if m.Line == 0 {
+		m.Line = 2
+	}
+	if m.Message == 0 {
+		m.Message = 3
+	}
+	return nil
+}
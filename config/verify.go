@@ -0,0 +1,83 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrSignatureInvalid is returned when the signature of the configuration
+// file can't be verified
+var ErrSignatureInvalid = errors.New("signature of configuration file is invalid")
+
+// Verifier verify detached signature of the configuration file
+type Verifier interface {
+	// Verify verify b which is content of the file fn
+	Verify(fn string, b []byte) error
+}
+
+// Minisign verify the signature in fn+".minisig" with minisign
+type Minisign struct {
+	// PublicKey is the public key or path to the public key file
+	PublicKey string
+}
+
+// Verify call minisign -V
+func (m *Minisign) Verify(fn string, b []byte) error {
+	f, err := ioutil.TempFile("", "goemon")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-V", "-q", "-m", f.Name(), "-x", fn + ".minisig"}
+	if _, err := os.Stat(m.PublicKey); err == nil {
+		args = append(args, "-p", m.PublicKey)
+	} else {
+		args = append(args, "-P", m.PublicKey)
+	}
+	return verifyCommand(exec.Command("minisign", args...))
+}
+
+// SSHSignature verify the signature in fn+".sig" with ssh-keygen -Y. The
+// signature must be created with namespace "goemon" by one of signers in
+// AllowedSigners.
+type SSHSignature struct {
+	AllowedSigners string
+}
+
+// Verify call ssh-keygen -Y verify
+func (s *SSHSignature) Verify(fn string, b []byte) error {
+	sig := fn + ".sig"
+	out, err := exec.Command("ssh-keygen", "-Y", "find-principals", "-f", s.AllowedSigners, "-s", sig).Output()
+	if err != nil {
+		return fmt.Errorf("%w: no principal is found for %s", ErrSignatureInvalid, sig)
+	}
+	principal := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+	cmd := exec.Command("ssh-keygen", "-Y", "verify", "-f", s.AllowedSigners, "-I", principal, "-n", "goemon", "-s", sig)
+	cmd.Stdin = bytes.NewReader(b)
+	return verifyCommand(cmd)
+}
+
+func verifyCommand(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("%w: %s", ErrSignatureInvalid, strings.TrimSpace(stderr.String()))
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package goemon
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const defaultConfirmTimeout = 30 * time.Second
+
+// terminal open the terminal to read the answer. It is opened only while
+// asking, so goemon never steal stdin from commands.
+func (g *Goemon) terminal() (io.ReadCloser, error) {
+	if g.input != nil {
+		return ioutil.NopCloser(g.input), nil
+	}
+	if runtime.GOOS == "windows" {
+		return os.Open("CONIN$")
+	}
+	return os.Open("/dev/tty")
+}
+
+// readLine read one line from r. It read byte by byte not to consume input
+// after the line.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				return strings.TrimSpace(string(line)), nil
+			}
+			line = append(line, b[0])
+		}
+		if err != nil {
+			return strings.TrimSpace(string(line)), err
+		}
+	}
+}
+
+// confirm ask y/N with message. It answer no when timed out, or when there
+// is no terminal.
+func (g *Goemon) confirm(message string) bool {
+	g.confirmMutex.Lock()
+	defer g.confirmMutex.Unlock()
+
+	timeout := defaultConfirmTimeout
+	if g.conf.ConfirmTimeout != "" {
+		d, err := time.ParseDuration(g.conf.ConfirmTimeout)
+		if err != nil {
+			g.Logger.Println("invalid confirm_timeout:", err)
+		} else {
+			timeout = d
+		}
+	}
+
+	in, err := g.terminal()
+	if err != nil {
+		g.Logger.Println("can't ask without terminal:", err)
+		return false
+	}
+	// closing the terminal stop reading when timed out
+	defer in.Close()
+
+	if message == "" {
+		message = "Continue?"
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", message)
+	answer := make(chan string, 1)
+	go func() {
+		line, err := readLine(in)
+		if err != nil && line == "" {
+			close(answer)
+			return
+		}
+		answer <- line
+	}()
+	select {
+	case line, ok := <-answer:
+		if !ok {
+			fmt.Fprintln(os.Stderr)
+			return false
+		}
+		switch strings.ToLower(line) {
+		case "y", "yes":
+			return true
+		}
+		return false
+	case <-time.After(timeout):
+		fmt.Fprintln(os.Stderr)
+		g.Logger.Println("timed out to confirm")
+		return false
+	}
+}
@@ -0,0 +1,91 @@
+// Package console make output of goemon and commands readable on consoles
+// which don't understand ANSI escape sequences or UTF-8 like old cmd.exe.
+package console
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+var (
+	// Stdout is os.Stdout if the console understand ANSI escape sequences.
+	// Otherwise, it strips them.
+	Stdout io.Writer = os.Stdout
+
+	// Stderr is same as Stdout for os.Stderr
+	Stderr io.Writer = os.Stderr
+
+	restore     = func() {}
+	restoreOnce sync.Once
+)
+
+// Restore restore settings of the console changed by this package like the
+// code page on Windows. It should be called before exit.
+func Restore() {
+	restoreOnce.Do(restore)
+}
+
+const (
+	stateText = iota
+	stateEsc
+	stateCSI
+	stateOSC
+	stateOSCEsc
+)
+
+// stripWriter remove ANSI escape sequences. Sequences split into multiple
+// writes are also removed.
+type stripWriter struct {
+	w     io.Writer
+	state int
+	mutex sync.Mutex
+}
+
+// Strip return writer which remove ANSI escape sequences like colors
+func Strip(w io.Writer) io.Writer {
+	return &stripWriter{w: w}
+}
+
+func (s *stripWriter) Write(b []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		switch s.state {
+		case stateText:
+			if c == 0x1b {
+				s.state = stateEsc
+			} else {
+				out = append(out, c)
+			}
+		case stateEsc:
+			switch c {
+			case '[':
+				s.state = stateCSI
+			case ']':
+				s.state = stateOSC
+			default:
+				s.state = stateText
+			}
+		case stateCSI:
+			// parameters and intermediate bytes until the final byte
+			if c >= 0x40 && c <= 0x7e {
+				s.state = stateText
+			}
+		case stateOSC:
+			// terminated by BEL or ESC \
+			if c == 0x07 {
+				s.state = stateText
+			} else if c == 0x1b {
+				s.state = stateOSCEsc
+			}
+		case stateOSCEsc:
+			s.state = stateText
+		}
+	}
+	if _, err := s.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,43 @@
+package console
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		input    []string
+		expected string
+	}{
+		{[]string{"\x1b[31mred\x1b[0m"}, "red"},
+		{[]string{"\x1b[1;3", "2mgreen\x1b", "[0m done"}, "green done"},
+		{[]string{"\x1b]0;title\x07ファイル.txt"}, "ファイル.txt"},
+		{[]string{"\x1b]8;;http://example.com\x1b\\link\x1b]8;;\x1b\\"}, "link"},
+		{[]string{"plain text\n"}, "plain text\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		w := Strip(&buf)
+		for _, s := range test.input {
+			if n, err := w.Write([]byte(s)); err != nil || n != len(s) {
+				t.Fatal("Should be succeeded", n, err)
+			}
+		}
+		if buf.String() != test.expected {
+			t.Fatalf("Should be %q but got %q", test.expected, buf.String())
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	n := 0
+	restore = func() {
+		n++
+	}
+	Restore()
+	Restore()
+	if n != 1 {
+		t.Fatal("Should restore once:", n)
+	}
+}
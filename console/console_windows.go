@@ -0,0 +1,50 @@
+package console
+
+import (
+	"os"
+	"syscall"
+)
+
+const enableVirtualTerminalProcessing = 0x0004
+
+var (
+	libkernel32            = syscall.MustLoadDLL("kernel32")
+	procSetConsoleMode     = libkernel32.MustFindProc("SetConsoleMode")
+	procSetConsoleOutputCP = libkernel32.MustFindProc("SetConsoleOutputCP")
+	procGetConsoleOutputCP = libkernel32.MustFindProc("GetConsoleOutputCP")
+)
+
+func init() {
+	// output of commands like go and node is UTF-8. Without this, non-ASCII
+	// file names are broken on consoles of other code pages like CP932. The
+	// code page is restored by Restore because it is kept in the console
+	// after goemon exited.
+	if cp, _, _ := procGetConsoleOutputCP.Call(); cp != 0 && cp != 65001 {
+		procSetConsoleOutputCP.Call(65001)
+		restore = func() {
+			procSetConsoleOutputCP.Call(cp)
+		}
+	}
+
+	if !enableVT(os.Stdout) {
+		Stdout = Strip(os.Stdout)
+	}
+	if !enableVT(os.Stderr) {
+		Stderr = Strip(os.Stderr)
+	}
+}
+
+// enableVT enable ANSI escape sequences on the console. It return true if
+// f is not a console because files and pipes keep the sequences as is.
+func enableVT(f *os.File) bool {
+	h := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(h, &mode); err != nil {
+		return true
+	}
+	if mode&enableVirtualTerminalProcessing != 0 {
+		return true
+	}
+	r, _, _ := procSetConsoleMode.Call(uintptr(h), uintptr(mode|enableVirtualTerminalProcessing))
+	return r != 0
+}
@@ -0,0 +1,205 @@
+package goemon
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// tokenPath return the file which has the token of the control endpoint for
+// the configuration. It is in the runtime directory of the user, so only the
+// user can read it.
+func tokenPath(file string) (string, error) {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	sum := sha256.Sum256([]byte(file))
+	return filepath.Join(dir, "goemon", hex.EncodeToString(sum[:8])+".token"), nil
+}
+
+// writeToken generate new token of the control endpoint for this session
+func (g *Goemon) writeToken() error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+	fn, err := tokenPath(g.File)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(fn), 0700); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(fn, []byte(token), 0600); err != nil {
+		return err
+	}
+	g.token.Store(token)
+	return nil
+}
+
+// readToken return the token written by goemon running with the
+// configuration
+func (g *Goemon) readToken() string {
+	fn, err := tokenPath(g.File)
+	if err != nil {
+		return ""
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// loopbackHost return true if host of the request is the loopback address.
+// Other hosts are refused to prevent DNS rebinding.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// control return handler for goemon commands running on the same machine,
+// like git hooks. Only requests from loopback addresses to loopback hosts
+// with the token of the session are accepted.
+func (g *Goemon) control() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/goemon/ctl/events", g.webhook.serve)
+	mux.HandleFunc("/goemon/ctl/which", g.serveWhich)
+	mux.HandleFunc("/goemon/ctl/problems", g.serveProblems)
+	mux.HandleFunc("/goemon/ctl/health", func(w http.ResponseWriter, r *http.Request) {
+		if err := g.health(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ok")
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if ip := net.ParseIP(host); err != nil || ip == nil || !ip.IsLoopback() || !loopbackHost(r.Host) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		token, _ := g.token.Load().(string)
+		if token == "" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if err = checkRequest(r, token); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// controlURL return URL of the control endpoint of goemon running with the
+// configuration
+func (g *Goemon) controlURL(path string) string {
+	addr := g.liveReloadAddr()
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr + "/goemon/ctl/" + path
+}
+
+// controlRequest send the request to the control endpoint with the token
+func (g *Goemon) controlRequest(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, g.controlURL(path), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(tokenHeader, g.readToken())
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return http.DefaultClient.Do(req)
+}
+
+func (g *Goemon) setLoadError(err error) {
+	g.healthMutex.Lock()
+	defer g.healthMutex.Unlock()
+	g.loadErr = err
+}
+
+// health return error if the configuration is not loaded or the command is
+// not running
+func (g *Goemon) health() error {
+	g.healthMutex.Lock()
+	err := g.loadErr
+	g.healthMutex.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %v", g.File, err)
+	}
+	if len(g.Args) > 0 && atomic.LoadInt32(&g.running) == 0 {
+		return errors.New("command is not running")
+	}
+	for _, p := range g.procs {
+		if p.restart == "always" && atomic.LoadInt32(&p.running) == 0 {
+			return fmt.Errorf("process %s is not running", p.name)
+		}
+	}
+	return nil
+}
+
+// HealthCheck ask goemon running on the same machine with the configuration
+// whether it is healthy. It return nil if healthy.
+func (g *Goemon) HealthCheck() error {
+	resp, err := g.controlRequest(http.MethodGet, "health", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unhealthy: %s", strings.TrimSpace(string(b)))
+	}
+	return nil
+}
+
+// Send send events of paths to goemon running on the same machine with the
+// configuration. Relative paths are resolved from the current directory.
+func (g *Goemon) Send(paths []string) error {
+	req := webhookRequest{Op: "write"}
+	for _, path := range paths {
+		if fn, err := filepath.Abs(path); err == nil {
+			path = fn
+		}
+		req.Paths = append(req.Paths, filepath.ToSlash(path))
+	}
+	b, err := json.Marshal(&req)
+	if err != nil {
+		return err
+	}
+	resp, err := g.controlRequest(http.MethodPost, "events", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status: %v", resp.Status)
+	}
+	return nil
+}
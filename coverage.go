@@ -0,0 +1,269 @@
+package goemon
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCoverageTTL is how long the coverage map is trusted by default. It
+// is changed by coverage_ttl.
+const defaultCoverageTTL = time.Hour
+
+// coverTest is the test which cover the file
+type coverTest struct {
+	Package string `json:"package"`
+	Test    string `json:"test"`
+}
+
+// coverageMap map files to tests which execute them. It is built by running
+// each test with coverage in background, and stored in the cache directory.
+type coverageMap struct {
+	Time  time.Time              `json:"time"`
+	Files map[string][]coverTest `json:"files"`
+}
+
+// coverage hold coverage maps for directories where :gotest run
+type coverage struct {
+	mutex sync.Mutex
+	maps  map[string]*coverageMap
+	ttl   time.Duration
+
+	// refreshing is true while a refresh is running. Only one refresh run
+	// at a time. tried is the time when the refresh of each directory was
+	// started, so failed refreshes are not retried until ttl is passed.
+	refreshing bool
+	tried      map[string]time.Time
+
+	// running serialize go test of refresh and :gotest, so they don't run
+	// in parallel. refresh hold it for each test to not block :gotest long.
+	running sync.Mutex
+
+	// allowed return false if go command with args must not be executed
+	allowed func(args []string) bool
+}
+
+func coveragePath(dir string) string {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(dir))
+	return filepath.Join(cache, "goemon", "coverage-"+hex.EncodeToString(sum[:8])+".json")
+}
+
+// get return the coverage map of dir. It is read from the cache directory at
+// first.
+func (c *coverage) get(dir string) *coverageMap {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if m, ok := c.maps[dir]; ok {
+		return m
+	}
+	var m *coverageMap
+	if b, err := ioutil.ReadFile(coveragePath(dir)); err == nil {
+		m = &coverageMap{}
+		if json.Unmarshal(b, m) != nil {
+			m = nil
+		}
+	}
+	if c.maps == nil {
+		c.maps = map[string]*coverageMap{}
+	}
+	c.maps[dir] = m
+	return m
+}
+
+func (c *coverage) set(dir string, m *coverageMap) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.maps == nil {
+		c.maps = map[string]*coverageMap{}
+	}
+	c.maps[dir] = m
+	if fn := coveragePath(dir); fn != "" {
+		if b, err := json.Marshal(m); err == nil {
+			os.MkdirAll(filepath.Dir(fn), 0700)
+			ioutil.WriteFile(fn, b, 0600)
+		}
+	}
+}
+
+func (c *coverage) setTTL(ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.ttl = ttl
+}
+
+// expiry return ttl of coverage maps. c.mutex must be held.
+func (c *coverage) expiry() time.Duration {
+	if c.ttl > 0 {
+		return c.ttl
+	}
+	return defaultCoverageTTL
+}
+
+// stale return true if the coverage map of dir should be refreshed
+func (c *coverage) stale(dir string) bool {
+	m := c.get(dir)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return m == nil || time.Since(m.Time) > c.expiry()
+}
+
+// schedule start refresh of the coverage map of dir in background if it is
+// stale. It is not started while other refresh is running, or until ttl is
+// passed since the last refresh of dir was started even if it failed.
+func (c *coverage) schedule(dir string, pkgs []string, logger *log.Logger) {
+	if !c.stale(dir) {
+		return
+	}
+	c.mutex.Lock()
+	if c.refreshing || time.Since(c.tried[dir]) < c.expiry() {
+		c.mutex.Unlock()
+		return
+	}
+	c.refreshing = true
+	if c.tried == nil {
+		c.tried = map[string]time.Time{}
+	}
+	c.tried[dir] = time.Now()
+	c.mutex.Unlock()
+
+	go func() {
+		defer func() {
+			c.mutex.Lock()
+			c.refreshing = false
+			c.mutex.Unlock()
+		}()
+		logger.Println("refreshing coverage map")
+		if err := c.refresh(dir, pkgs); err != nil {
+			logger.Println("failed to refresh coverage map:", err)
+		}
+	}()
+}
+
+// selectTests return arguments of go test to run tests which cover file. It
+// return the package of file if the map is stale or doesn't know file.
+func (c *coverage) selectTests(dir, file string) []string {
+	if m := c.get(dir); m != nil && !c.stale(dir) && !strings.HasSuffix(file, "_test.go") {
+		if tests := m.Files[file]; len(tests) > 0 {
+			var pkgs, names []string
+			for _, t := range tests {
+				pkgs = appendUnique(pkgs, t.Package)
+				names = appendUnique(names, t.Test)
+			}
+			sort.Strings(pkgs)
+			sort.Strings(names)
+			return append([]string{"-run", "^(" + strings.Join(names, "|") + ")$"}, pkgs...)
+		}
+	}
+	rel, err := filepath.Rel(dir, filepath.Dir(file))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	return []string{"./" + filepath.ToSlash(rel)}
+}
+
+// refresh build the coverage map of pkgs in dir. It run each test with
+// coverage, so it takes long time. Use schedule to run it in background.
+func (c *coverage) refresh(dir string, pkgs []string) error {
+	b, err := c.goCommand(dir, append([]string{"list", "-f", "{{.ImportPath}}\t{{.Dir}}"}, pkgs...)...)
+	if err != nil {
+		return err
+	}
+	dirs := map[string]string{}
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		if fields := strings.SplitN(line, "\t", 2); len(fields) == 2 {
+			dirs[fields[0]] = fields[1]
+			paths = append(paths, fields[0])
+		}
+	}
+
+	tmp, err := ioutil.TempDir("", "goemon")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	profile := filepath.Join(tmp, "cover.out")
+
+	m := &coverageMap{Time: time.Now(), Files: map[string][]coverTest{}}
+	for _, pkg := range paths {
+		b, err := c.goCommand(dir, "test", "-list", ".", pkg)
+		if err != nil {
+			continue
+		}
+		for _, test := range strings.Fields(string(b)) {
+			if !strings.HasPrefix(test, "Test") {
+				continue
+			}
+			os.Remove(profile)
+			c.goCommand(dir, "test", "-count=1", "-run", "^"+test+"$",
+				"-coverpkg="+strings.Join(paths, ","), "-coverprofile="+profile, pkg)
+			files, err := coveredFiles(profile)
+			if err != nil {
+				continue
+			}
+			for _, f := range files {
+				fdir, ok := dirs[path.Dir(f)]
+				if !ok {
+					continue
+				}
+				fn := filepath.ToSlash(filepath.Join(fdir, path.Base(f)))
+				m.Files[fn] = append(m.Files[fn], coverTest{Package: pkg, Test: test})
+			}
+		}
+	}
+	c.set(dir, m)
+	return nil
+}
+
+func (c *coverage) goCommand(dir string, args ...string) ([]byte, error) {
+	if c.allowed != nil && !c.allowed(append([]string{"go"}, args...)) {
+		return nil, fmt.Errorf("command is not allowed: go %v", strings.Join(args, " "))
+	}
+	if args[0] == "test" {
+		c.running.Lock()
+		defer c.running.Unlock()
+	}
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
+
+// coveredFiles return files which have executed statements in the profile
+func coveredFiles(profile string) ([]string, error) {
+	b, err := ioutil.ReadFile(profile)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		// example/a/a.go:3.20,5.2 1 1
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 || fields[2] == "0" {
+			continue
+		}
+		i := strings.LastIndex(fields[0], ":")
+		if i < 0 {
+			continue
+		}
+		files = appendUnique(files, fields[0][:i])
+	}
+	return files, scanner.Err()
+}
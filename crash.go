@@ -0,0 +1,85 @@
+package goemon
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"time"
+)
+
+// secretRe match values of settings which look like secrets
+var secretRe = regexp.MustCompile(`(?i)((?:token|secret|password|passwd|apikey|api_key|auth|credential)[a-z_]*\s*[=:]\s*)("[^"]*"|'[^']*'|\S+)`)
+
+// userinfoRe match user and password in URLs
+var userinfoRe = regexp.MustCompile(`(://)[^/@\s]+@`)
+
+// headerRe match values of headers which have credentials
+var headerRe = regexp.MustCompile(`(?im)((?:proxy-)?(?:authorization|cookie)\s*:\s*).*$`)
+
+// redact hide secrets in s
+func redact(s string) string {
+	s = headerRe.ReplaceAllString(s, "${1}***")
+	s = secretRe.ReplaceAllString(s, "${1}***")
+	return userinfoRe.ReplaceAllString(s, "${1}***@")
+}
+
+// crashDir return the directory to write crash reports
+func crashDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return os.TempDir()
+	}
+	return filepath.Join(dir, "goemon")
+}
+
+// recoverCrash write crash report when goemon panicked, and panic again. It
+// must be deferred at the top of goroutines.
+func (g *Goemon) recoverCrash() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	fn, err := g.writeCrash(crashDir(), r, debug.Stack())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "goemon: failed to write crash report:", err)
+	} else {
+		fmt.Fprintln(os.Stderr, "goemon: crashed. please attach the report to the issue:", fn)
+	}
+	panic(r)
+}
+
+// writeCrash write the crash report into dir and return the path to it. The
+// report doesn't contain environment variables and commands because they can
+// have secrets in any form. Only the number of them and patterns of tasks are
+// written.
+func (g *Goemon) writeCrash(dir string, r interface{}, stack []byte) (string, error) {
+	var buf bytes.Buffer
+	now := time.Now()
+	fmt.Fprintf(&buf, "goemon crash report %s\n\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&buf, "panic: %v\n\n", redact(fmt.Sprint(r)))
+	fmt.Fprintf(&buf, "go: %s\nos: %s\narch: %s\ncpus: %d\n", runtime.Version(), runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+	fmt.Fprintf(&buf, "goroutines: %d\n\n", runtime.NumGoroutine())
+
+	fmt.Fprintf(&buf, "config: %s\n", g.File)
+	fmt.Fprintf(&buf, "command: %d arguments\n", len(g.Args))
+	fmt.Fprintf(&buf, "processes: %d\n", len(g.conf.Processes))
+	fmt.Fprintf(&buf, "tasks: %d\n", len(g.conf.Tasks))
+	for _, t := range g.conf.Tasks {
+		if t.Task == nil {
+			continue
+		}
+		fmt.Fprintf(&buf, "- match: %s (%d commands)\n", redact(t.Match), len(t.Commands))
+	}
+	fmt.Fprintf(&buf, "\n%s", redact(string(stack)))
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	fn := filepath.Join(dir, "crash-"+now.Format("20060102-150405")+".txt")
+	return fn, ioutil.WriteFile(fn, buf.Bytes(), 0600)
+}
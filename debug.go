@@ -0,0 +1,113 @@
+package goemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/mattn/goemon/commands"
+)
+
+const defaultDebugAddr = "127.0.0.1:2345"
+
+// gobuild is :gobuild [-o output] [package]. It build the package like go
+// build. With debug: true, it build without optimizations, and restart the
+// command under delve to attach debuggers.
+func (g *Goemon) gobuild(ctx *commands.Context, args ...string) error {
+	output := ""
+	if len(args) > 1 && args[0] == "-o" {
+		output, args = args[1], args[2:]
+	}
+	pkg := "."
+	if len(args) > 0 {
+		pkg = args[0]
+	}
+	if len(args) > 1 {
+		return fmt.Errorf(":gobuild accept only one package")
+	}
+	if output == "" {
+		dir, err := filepath.Abs(ctx.Path("."))
+		if err != nil {
+			return err
+		}
+		output = filepath.Base(dir)
+		if runtime.GOOS == "windows" {
+			output += ".exe"
+		}
+	}
+	output, err := filepath.Abs(ctx.Path(output))
+	if err != nil {
+		return err
+	}
+
+	buildArgs := []string{"build", "-o", output}
+	if g.conf.Debug {
+		buildArgs = append(buildArgs, "-gcflags=all=-N -l")
+	}
+	cmd, err := g.command("go", append(buildArgs, pkg)...)
+	if err != nil {
+		return err
+	}
+	cmd.Dir = ctx.Dir
+	cmd.Stdout = ctx.Stdout
+	cmd.Stderr = ctx.Stderr
+	g.Logger.Println("executing", strings.Join(cmd.Args, " "))
+	if err = cmd.Run(); err != nil {
+		return err
+	}
+	if !g.conf.Debug {
+		// restart the command without delve if it was debugged
+		if target, _ := g.debugTarget.Load().(string); target != "" {
+			g.debugTarget.Store("")
+			return g.terminate(os.Interrupt)
+		}
+		return nil
+	}
+	if len(g.Args) == 0 {
+		g.Logger.Println("not debugging", output, "because no command is supervised")
+		return nil
+	}
+	g.debugTarget.Store(output)
+	return g.terminate(os.Interrupt)
+}
+
+// debugArgs return the command line to run target under delve. Arguments of
+// the command are passed to target if the command run target.
+func (g *Goemon) debugArgs(target string) []string {
+	addr := g.conf.DebugAddr
+	if addr == "" {
+		addr = defaultDebugAddr
+	}
+	args := []string{
+		"dlv", "exec", target,
+		"--headless", "--listen=" + addr, "--api-version=2",
+		"--accept-multiclient", "--continue",
+	}
+
+	command := g.Args
+	if g.conf.Command != "" && len(command) == 3 && command[2] == g.conf.Command {
+		// command is run by shell
+		command = strings.Fields(g.conf.Command)
+	}
+	if len(command) > 1 && sameFile(command[0], target) {
+		args = append(args, "--")
+		args = append(args, command[1:]...)
+	}
+	return args
+}
+
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		if fa, err = os.Stat(a + ".exe"); err != nil {
+			return false
+		}
+	}
+	fb, err := os.Stat(b)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(fa, fb)
+}
@@ -0,0 +1,105 @@
+package goemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/goemon/config"
+)
+
+// defaultDepsFiles are files which list dependencies of the project
+var defaultDepsFiles = []string{
+	"go.mod",
+	"go.sum",
+	"package.json",
+	"package-lock.json",
+	"yarn.lock",
+	"pnpm-lock.yaml",
+}
+
+// loadDeps make the task to run deps_command
+func (g *Goemon) loadDeps() {
+	if g.conf.DepsCommand == "" {
+		return
+	}
+	t := &task{Task: &config.Task{
+		Match:     "deps_command",
+		Commands:  []string{g.conf.DepsCommand},
+		Exclusive: true,
+	}}
+	if g.conf.Timeout != "" {
+		var err error
+		if t.timeout, err = time.ParseDuration(g.conf.Timeout); err != nil {
+			g.Logger.Println("invalid command_timeout:", err)
+		}
+	}
+	t.done = g.restartDeps
+	g.conf.deps = t
+}
+
+// isDeps return true if the file lists dependencies. Files are matched by
+// the path relative to the directory of the configuration file or roots of
+// tasks, so files of packages in node_modules or vendor are not matched.
+func (g *Goemon) isDeps(file string) bool {
+	if g.conf.deps == nil {
+		return false
+	}
+	fn, err := filepath.Abs(filepath.FromSlash(file))
+	if err != nil {
+		return false
+	}
+	files := g.conf.DepsFiles
+	if len(files) == 0 {
+		files = defaultDepsFiles
+	}
+	roots := g.roots()
+	if dir, err := filepath.Abs(filepath.Dir(g.File)); err == nil {
+		roots = append(roots, dir)
+	}
+	for _, root := range roots {
+		if !within(root, fn) {
+			continue
+		}
+		rel, err := filepath.Rel(root, fn)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.Contains("/"+rel, "/node_modules/") || strings.Contains("/"+rel, "/vendor/") {
+			continue
+		}
+		for _, f := range files {
+			if f == rel {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// updateDeps run deps_command in background, and restart the process when
+// it succeeded. Other tasks wait for it because it is exclusive. Events which
+// happened while running deps_command are ignored because go.mod and go.sum
+// are usually changed at once.
+func (g *Goemon) updateDeps(event Event) {
+	t := g.conf.deps
+	t.mutex.Lock()
+	finished := t.finished
+	t.mutex.Unlock()
+	if !event.Time.IsZero() && event.Time.Before(finished) {
+		return
+	}
+	if g.fire(t, filepath.ToSlash(event.Path), event.Time) {
+		g.Logger.Println("dependencies changed:", event.Path)
+	}
+}
+
+// restartDeps restart the process after deps_command succeeded
+func (g *Goemon) restartDeps(ok bool) {
+	if ok && (atomic.LoadInt32(&g.running) == 1 || len(g.procs) > 0) {
+		g.terminate(os.Interrupt)
+	}
+}
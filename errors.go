@@ -0,0 +1,41 @@
+package goemon
+
+import (
+	"fmt"
+
+	"github.com/mattn/goemon/config"
+	"github.com/mattn/goemon/watcher"
+)
+
+var (
+	// ErrConfigNotFound is returned when the configuration file does not exist
+	ErrConfigNotFound = config.ErrConfigNotFound
+
+	// ErrConfigNotOwned is returned when the configuration file is not owned
+	// by the current user. Commands in such files are never executed.
+	ErrConfigNotOwned = config.ErrConfigNotOwned
+
+	// ErrSignatureInvalid is returned when the signature of the configuration
+	// file can't be verified by Verifier
+	ErrSignatureInvalid = config.ErrSignatureInvalid
+
+	// ErrWatcherExhausted is returned when no more files or directories can be
+	// watched because the limit of the system is reached
+	ErrWatcherExhausted = watcher.ErrWatcherExhausted
+)
+
+// ErrPatternInvalid is returned when the pattern of the task can't be compiled
+type ErrPatternInvalid struct {
+	Task    int
+	Pattern string
+	Err     error
+}
+
+func (e *ErrPatternInvalid) Error() string {
+	return fmt.Sprintf("invalid pattern for task #%d: %q: %v", e.Task, e.Pattern, e.Err)
+}
+
+// Unwrap return the underlying error
+func (e *ErrPatternInvalid) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,27 @@
+package goemon
+
+import (
+	"github.com/mattn/goemon/watcher"
+)
+
+// Event is the event of the file, or the named event like ":Foo" fired by
+// commands. Events from all sources are delivered as Event regardless of
+// how files are watched.
+type Event = watcher.Event
+
+// Op is set of operations on the file. It is used for ops of tasks too.
+type Op = watcher.Op
+
+// Operations on the file
+const (
+	Create = watcher.Create
+	Write  = watcher.Write
+	Remove = watcher.Remove
+	Rename = watcher.Rename
+	Chmod  = watcher.Chmod
+)
+
+// NewEvent create new Event which happened now
+func NewEvent(path string, op Op) Event {
+	return watcher.NewEvent(path, op)
+}
@@ -0,0 +1,117 @@
+package goemon
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gitInfo hold git metadata of working directory. The values are computed
+// lazily and cached for a while because commands may refer them many times.
+type gitInfo struct {
+	mutex  sync.Mutex
+	branch string
+	dirty  string
+	bt     time.Time
+	dt     time.Time
+}
+
+const gitCacheDuration = time.Second
+
+func (gi *gitInfo) Branch() string {
+	gi.mutex.Lock()
+	defer gi.mutex.Unlock()
+	if time.Since(gi.bt) > gitCacheDuration {
+		b, err := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD").Output()
+		if err != nil {
+			gi.branch = ""
+		} else {
+			gi.branch = strings.TrimSpace(string(b))
+		}
+		gi.bt = time.Now()
+	}
+	return gi.branch
+}
+
+func (gi *gitInfo) Dirty() string {
+	gi.mutex.Lock()
+	defer gi.mutex.Unlock()
+	if time.Since(gi.dt) > gitCacheDuration {
+		b, err := exec.Command("git", "status", "--porcelain").Output()
+		if err != nil {
+			gi.dirty = ""
+		} else if len(strings.TrimSpace(string(b))) > 0 {
+			gi.dirty = "1"
+		} else {
+			gi.dirty = "0"
+		}
+		gi.dt = time.Now()
+	}
+	return gi.dirty
+}
+
+// gitDir return the path of git directory of working directory. It also
+// follow ".git" file which is used by worktrees and submodules.
+func gitDir() string {
+	fi, err := os.Stat(".git")
+	if err != nil {
+		return ""
+	}
+	if fi.IsDir() {
+		return ".git"
+	}
+	b, err := ioutil.ReadFile(".git")
+	if err != nil {
+		return ""
+	}
+	s := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(s, "gitdir:") {
+		return ""
+	}
+	return strings.TrimSpace(s[len("gitdir:"):])
+}
+
+// gitBusy return true while git hold index.lock for checking out, rebasing
+// or so. Rebases stopped for conflicts or editing are not busy because the
+// user edit files while it.
+func gitBusy() bool {
+	dir := gitDir()
+	if dir == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, "index.lock"))
+	return err == nil
+}
+
+// suspend queue the event while git operation is running. The queued events
+// are dispatched at once after the operation finished.
+func (g *Goemon) suspend(event Event) bool {
+	if !gitBusy() {
+		return false
+	}
+	g.pendingMutex.Lock()
+	defer g.pendingMutex.Unlock()
+	if g.pending == nil {
+		g.pending = map[string]Event{}
+		go func() {
+			g.Logger.Println("suspending tasks while git operation")
+			for gitBusy() {
+				time.Sleep(100 * time.Millisecond)
+			}
+			g.pendingMutex.Lock()
+			pending := g.pending
+			g.pending = nil
+			g.pendingMutex.Unlock()
+			g.Logger.Printf("resuming tasks with %d events", len(pending))
+			for _, event := range pending {
+				g.task(event)
+			}
+		}()
+	}
+	g.pending[event.Path] = event
+	return true
+}
@@ -1,69 +1,185 @@
 package goemon
 
 import (
-	"bytes"
-	"fmt"
-	"io/ioutil"
+	"encoding/json"
+	"errors"
+	"io"
 	"log"
-	"net"
 	"os"
-	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"regexp"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/fsnotify/fsnotify"
-	"github.com/omeid/livereload"
-	"gopkg.in/yaml.v2"
+	"github.com/mattn/goemon/commands"
+	"github.com/mattn/goemon/config"
+	"github.com/mattn/goemon/console"
+	"github.com/mattn/goemon/reload"
+	"github.com/mattn/goemon/runner"
+	"github.com/mattn/goemon/watcher"
 )
 
 const logFlag = log.Ldate | log.Ltime | log.Lshortfile
 
-var commandRe = regexp.MustCompile(`^\s*(:[a-z]+!?)(?:\s+(\S+))*$`)
-
 // Goemon is structure of this application
 type Goemon struct {
-	tasks uint64
-
-	File   string
-	Logger *log.Logger
-	Args   []string
-	lrc    net.Listener
-	lrs    *livereload.Server
-	fsw    *fsnotify.Watcher
-	cmd    *exec.Cmd
-	conf   conf
+	tasks   uint64
+	running int32
+
+	File     string
+	Logger   *log.Logger
+	Args     []string
+	Commands commands.Set
+
+	// ConfigOptional make goemon run without the configuration file, and
+	// load it when it is created.
+	ConfigOptional bool
+
+	// Verifier verify the signature of the configuration file before
+	// loading it if it is not nil.
+	Verifier config.Verifier
+
+	// NoCommand make goemon run only tasks and livereload. The command and
+	// processes are not started even if they are configured.
+	NoCommand bool
+
+	// AllowedCommands restricts commands which goemon execute if it is not
+	// empty. See allowedArgs for the format. It should be given by the user
+	// like -allow or LoadAllowedCommands, not by the configuration file
+	// which can be changed by others.
+	AllowedCommands []string
+
+	// Reloader is notified by :livereload instead of the builtin livereload
+	// server if it is not nil.
+	Reloader reload.Reloader
+
+	reloader    *reload.Server
+	reloaders   map[string]*reload.Server
+	reloadMutex sync.Mutex
+	fsw         *watcher.Watcher
+	proc        *runner.Process
+	procs       []*process
+	conf        conf
+
+	exclusive    sync.RWMutex
+	git          gitInfo
+	pending      map[string]Event
+	pendingMutex sync.Mutex
+	manual       *manualSource
+	webhook      *webhookSource
+	syncer       *syncer
+	sources      []EventSource
+	sourceMutex  sync.Mutex
+	started      bool
+	recorder     *json.Encoder
+	recordMutex  sync.Mutex
+	cache        taskCache
+	hashes       fileHashes
+	pollers      []*pollSource
+	input        io.Reader
+	output       io.Writer
+	confirmMutex sync.Mutex
+	loadErr      error
+	healthMutex  sync.Mutex
+	summary      summary
+	debugTarget  atomic.Value
+	failures     testFailures
+	coverage     coverage
+	problems     problems
+	latency      latency
+	token        atomic.Value
+
+	// fired count runs started or throttled by fire until they finish.
+	// benchStart is called instead of running tasks while Bench replays
+	// events.
+	fired      sync.WaitGroup
+	benchStart func(t *task, at time.Time)
 }
 
+// task is the task of the configuration with compiled patterns
 type task struct {
-	Match    string   `yaml:"match"`
-	Ignore   string   `yaml:"ignore"`
-	Commands []string `yaml:"commands"`
-	Ops      []string `yaml:"ops"`
-	mre      *regexp.Regexp
-	ire      *regexp.Regexp
-	mops     uint32
-	hit      bool
-	mutex    sync.Mutex
+	*config.Task
+	matcher   watcher.Matcher
+	dir       string
+	timeout   time.Duration
+	maxOutput int64
+	hit       bool
+	mutex     sync.Mutex
+
+	// minInterval is minimum duration between starts of runs. Runs are
+	// delayed until minInterval is passed since last.
+	minInterval time.Duration
+	last        time.Time
+	timer       *time.Timer
+	pending     string
+
+	// at is the time of the event which fired the run. It is the oldest
+	// event while the run is throttled.
+	at        time.Time
+	pendingAt time.Time
+
+	// out is output of commands instead of stdout and stderr. It is used to
+	// group output of the task in Once.
+	out io.Writer
+
+	// problemRe is compiled regexp of problem_matcher. capture is copy of
+	// output while the task runs to parse problems.
+	problemRe *regexp.Regexp
+	capture   *groupBuffer
+
+	// done is called with the result after each run by fire. finished is
+	// the time when the last run finished.
+	done     func(ok bool)
+	finished time.Time
+}
+
+func (t *task) stdout() io.Writer {
+	w := t.out
+	if w == nil {
+		w = console.Stdout
+	}
+	if t.capture != nil {
+		return io.MultiWriter(w, t.capture)
+	}
+	return w
+}
+
+func (t *task) stderr() io.Writer {
+	w := t.out
+	if w == nil {
+		w = console.Stderr
+	}
+	if t.capture != nil {
+		return io.MultiWriter(w, t.capture)
+	}
+	return w
 }
 
+// conf is the configuration with tasks ready to run
 type conf struct {
-	Command    string
-	LiveReload string  `yaml:"livereload"`
-	Tasks      []*task `yaml:"tasks"`
+	config.Config
+	Tasks []*task
+	deps  *task
+
+	latencyThreshold time.Duration
 }
 
 // New create new instance of goemon
 func New() *Goemon {
-	return &Goemon{
+	g := &Goemon{
 		File:   "goemon.yml",
-		Logger: log.New(os.Stderr, "GOEMON ", logFlag),
+		Logger: log.New(console.Stderr, "GOEMON ", logFlag),
+		manual: newManualSource(),
 	}
+	g.webhook = newWebhookSource(g)
+	g.coverage.allowed = g.allowedArgs
+	g.Commands = g.builtinCommands()
+	return g
 }
 
 // NewWithArgs create new instance of goemon with specified arguments by args
@@ -78,77 +194,35 @@ func Run() *Goemon {
 	return New().Run()
 }
 
-func compilePattern(pattern string) (*regexp.Regexp, error) {
-	if pattern[0] == '%' {
-		return regexp.Compile(pattern[1:])
-	}
-
-	var buf bytes.Buffer
-
-	for n, pat := range strings.Split(pattern, "|") {
-		if n == 0 {
-			buf.WriteString("^")
-		} else {
-			buf.WriteString("$|")
-		}
-		if fs, err := filepath.Abs(pat); err == nil {
-			pat = filepath.ToSlash(fs)
-		}
-		rs := []rune(pat)
-		for i := 0; i < len(rs); i++ {
-			if rs[i] == '/' {
-				if runtime.GOOS == "windows" {
-					buf.WriteString(`[/\\]`)
-				} else {
-					buf.WriteRune(rs[i])
-				}
-			} else if rs[i] == '*' {
-				if i < len(rs)-1 && rs[i+1] == '*' {
-					i++
-					if i < len(rs)-1 && rs[i+1] == '/' {
-						i++
-						buf.WriteString(`.*`)
-					} else {
-						return nil, fmt.Errorf("invalid wildcard: %s", pattern)
-					}
-				} else {
-					buf.WriteString(`[^/]+`)
-				}
-			} else if rs[i] == '?' {
-				buf.WriteString(`\S`)
-			} else {
-				buf.WriteString(fmt.Sprintf(`[\x%x]`, rs[i]))
-			}
-		}
-		buf.WriteString("$")
-	}
-
-	return regexp.Compile(buf.String())
-}
-
 func (g *Goemon) restart() error {
 	if len(g.Args) == 0 {
 		return nil
 	}
+	if g.proc != nil {
+		g.notify("RELOADING=1")
+	}
 	g.terminate(nil)
 	return g.spawn()
 }
 
 func (t *task) match(file string) bool {
-	return (t.mre != nil && t.mre.MatchString(file)) && (t.ire == nil || !t.ire.MatchString(file))
+	return t.matcher.MatchFile(file)
 }
 
-func (t *task) matchOp(op fsnotify.Op) bool {
-	if t.mops == 0 {
-		return true
-	}
-	return uint32(op)&t.mops == uint32(op)
+func (t *task) matchOp(op Op) bool {
+	return t.matcher.MatchOp(op)
 }
 
-func (g *Goemon) task(event fsnotify.Event) {
-	file := filepath.ToSlash(event.Name)
+// matchTasks return tasks which should be fired by the event
+func (g *Goemon) matchTasks(event Event) []*task {
+	var tasks []*task
+	file := filepath.ToSlash(event.Path)
 	for _, t := range g.conf.Tasks {
-		if strings.HasPrefix(event.Name, ":") {
+		if t.WatchURL != "" || t.WatchCmd != "" {
+			if t.watchName() != event.Path {
+				continue
+			}
+		} else if strings.HasPrefix(event.Path, ":") {
 			if t.Match != file {
 				continue
 			}
@@ -160,88 +234,257 @@ func (g *Goemon) task(event fsnotify.Event) {
 		if !t.matchOp(event.Op) {
 			continue
 		}
-		t.mutex.Lock()
-		if t.hit {
-			t.mutex.Unlock()
-			continue
+		tasks = append(tasks, t)
+		if g.conf.FirstMatch {
+			break
 		}
-		t.hit = true
+	}
+	return tasks
+}
+
+func (g *Goemon) task(event Event) {
+	file := filepath.ToSlash(event.Path)
+	if g.isDeps(file) {
+		g.updateDeps(event)
+	}
+	for _, t := range g.matchTasks(event) {
+		if g.fire(t, file, event.Time) {
+			g.Logger.Println(event)
+		}
+	}
+}
+
+// fire run the task in background, and return true if it is started. The
+// task is not started while it is running. When min_interval is not passed
+// since the last run, the run is delayed with the latest file. at is the time
+// of the event to measure latency, or zero.
+func (g *Goemon) fire(t *task, file string, at time.Time) bool {
+	t.mutex.Lock()
+	if t.hit {
 		t.mutex.Unlock()
-		g.Logger.Println(event)
-		go func(name string, t *task) {
-			atomic.AddUint64(&g.tasks, 1)
-
-		loopCommand:
-			for _, command := range t.Commands {
-				switch {
-				case commandRe.MatchString(command):
-					if !g.internalCommand(command, file) {
-						break loopCommand
-					}
-				default:
-					if !g.externalCommand(command, file) {
-						break loopCommand
-					}
+		return false
+	}
+	if wait := t.minInterval - time.Since(t.last); t.minInterval > 0 && wait > 0 {
+		t.pending = file
+		if t.pendingAt.IsZero() || at.Before(t.pendingAt) {
+			t.pendingAt = at
+		}
+		if t.timer == nil {
+			g.Logger.Println("throttling", t.Match, "for", wait.Round(time.Millisecond))
+			g.fired.Add(1)
+			t.timer = time.AfterFunc(wait, func() {
+				defer g.fired.Done()
+				t.mutex.Lock()
+				t.timer = nil
+				file, at := t.pending, t.pendingAt
+				t.pendingAt = time.Time{}
+				t.mutex.Unlock()
+				if g.fire(t, file, at) {
+					g.Logger.Println("running throttled task", t.Match)
 				}
-			}
+			})
+		}
+		t.mutex.Unlock()
+		return false
+	}
+	t.hit = true
+	t.last = time.Now()
+	t.at = at
+	t.mutex.Unlock()
+	g.fired.Add(1)
+	go func() {
+		defer g.fired.Done()
+		defer g.recoverCrash()
+		finish := func() {
 			t.mutex.Lock()
 			t.hit = false
+			t.finished = time.Now()
 			t.mutex.Unlock()
-			atomic.AddUint64(&g.tasks, ^uint64(0))
-		}(event.Name, t)
+		}
+		if g.benchStart != nil {
+			g.benchStart(t, at)
+			finish()
+			return
+		}
+		ok := g.run(t, file)
+		g.countRun(ok)
+		finish()
+		if t.done != nil {
+			t.done(ok)
+		}
+	}()
+	return true
+}
+
+// run run commands of the task, and return true if all commands succeeded
+func (g *Goemon) run(t *task, file string) bool {
+	atomic.AddUint64(&g.tasks, 1)
+	defer atomic.AddUint64(&g.tasks, ^uint64(0))
+
+	if t.Exclusive {
+		g.exclusive.Lock()
+		defer g.exclusive.Unlock()
+	} else {
+		g.exclusive.RLock()
+		defer g.exclusive.RUnlock()
+	}
+	if !t.at.IsZero() {
+		defer g.measure(t, t.at, time.Now())
+	}
+
+	var hash string
+	if t.Cache {
+		hash = g.inputHash(t)
+		if hash != "" && g.cache.get(t.key()) == hash {
+			g.Logger.Println("skipping", t.Match, "because inputs are not changed")
+			return true
+		}
+	}
+
+	if t.problemRe != nil {
+		t.capture = &groupBuffer{}
+	}
+	ok := g.runCommands(t, t.Commands, file) && g.runPipe(t, file)
+	if t.capture != nil {
+		g.reportProblems(t)
+		t.capture = nil
+	}
+	if ok {
+		ok = g.runCommands(t, t.OnSuccess, file)
+	} else if len(t.OnFailure) > 0 {
+		g.Logger.Println("running on_failure of", t.Match)
+		g.runCommands(t, t.OnFailure, file)
+	}
+
+	if ok && hash != "" {
+		if err := g.cache.put(t.key(), hash); err != nil {
+			g.Logger.Println("failed to save cache:", err)
+		}
+	}
+	return ok
+}
+
+// runCommands run commands in order, and return true if all commands
+// succeeded. It stops at the first failure.
+func (g *Goemon) runCommands(t *task, cmds []string, file string) bool {
+	for _, command := range cmds {
+		// placeholders are expanded after splitting pipelines because file
+		// names can contain " | "
+		if isPipeline(command) {
+			if !g.runPipeline(t, splitPipeline(command), file) {
+				return false
+			}
+			continue
+		}
+		command, err := g.expandStage(command, file)
+		if err != nil {
+			g.Logger.Println(err)
+			return false
+		}
+		if commands.IsCommand(command) {
+			if !g.internalCommand(t, command, file) {
+				return false
+			}
+		} else if !g.externalCommand(t, command) {
+			return false
+		}
+	}
+	return true
+}
+
+// expandStage expand placeholders in the command. Values are quoted for
+// external commands which are run by shell.
+func (g *Goemon) expandStage(command, file string) (string, error) {
+	if commands.IsCommand(command) {
+		return g.expand(command, file)
+	}
+	return g.expandShell(command, file)
+}
+
+// runPipeline expand placeholders in stages and run them as pipeline
+func (g *Goemon) runPipeline(t *task, stages []string, file string) bool {
+	expanded := make([]string, len(stages))
+	for i, stage := range stages {
+		var err error
+		if expanded[i], err = g.expandStage(stage, file); err != nil {
+			g.Logger.Println(err)
+			return false
+		}
+	}
+	return g.pipeline(t, expanded, file)
+}
+
+// runPipe run pipe of the task
+func (g *Goemon) runPipe(t *task, file string) bool {
+	if len(t.Pipe) == 0 {
+		return true
 	}
+	return g.runPipeline(t, t.Pipe, file)
 }
 
 func (g *Goemon) watch() error {
 	var err error
-	g.fsw, err = fsnotify.NewWatcher()
+	g.fsw, err = watcher.New()
+	if err != nil {
+		return err
+	}
+	err = g.fsw.Add(g.File)
+	if os.IsNotExist(err) && g.conf.ConfigOptional {
+		// wait for the configuration file to be created
+		if err = g.fsw.Add(filepath.Dir(g.File)); err == nil {
+			if _, serr := os.Stat(g.File); serr == nil {
+				g.fsw.Close()
+				return nil
+			}
+		}
+	}
 	if err != nil {
+		g.fsw.Close()
 		return err
 	}
-	g.fsw.Add(g.File)
 
 	root, err := filepath.Abs(".")
 	if err != nil {
 		g.Logger.Println(err)
 	}
 
-	dup := map[string]bool{}
-	g.fsw.Add(root)
-	dup[root] = true
-
-	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if info == nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
-		dir := filepath.Dir(path)
-		if _, ok := dup[dir]; !ok {
-			for _, t := range g.conf.Tasks {
-				if t.match(path) {
-					g.fsw.Add(dir)
-					dup[dir] = true
-					break
-				}
+	filter := func(path string) bool {
+		for _, t := range g.conf.Tasks {
+			if t.match(path) {
+				return true
 			}
 		}
-		return nil
-	})
+		return g.isDeps(path)
+	}
+	err = g.fsw.AddTree(root, filter)
+	for _, dir := range g.roots() {
+		if err != nil {
+			break
+		}
+		if rel, rerr := filepath.Rel(root, dir); rerr == nil && !strings.HasPrefix(rel, "..") {
+			continue
+		}
+		err = g.fsw.AddTree(dir, filter)
+	}
+	if errors.Is(err, ErrWatcherExhausted) {
+		g.fsw.Close()
+		return err
+	}
 	if err != nil {
 		g.Logger.Println(err)
 	}
 
 	g.Logger.Println("goemon loaded", g.File)
 
+	src := EventSource(g.fsw)
 	for {
 		select {
-		case event := <-g.fsw.Events:
-			if event.Name == g.File {
+		case event := <-src.Events():
+			if event.Path == g.File {
 				return nil
 			}
-			g.task(event)
-		case err := <-g.fsw.Errors:
+			g.dispatch(event)
+		case err := <-g.fsw.Errors():
 			if err != nil {
 				g.Logger.Println("error:", err)
 			}
@@ -250,79 +493,220 @@ func (g *Goemon) watch() error {
 }
 
 func (g *Goemon) load() error {
-	g.conf.Tasks = []*task{}
+	optional := g.ConfigOptional || g.conf.ConfigOptional
+	g.conf = conf{Tasks: []*task{}}
 	fn, err := filepath.Abs(g.File)
 	if err != nil {
 		return err
 	}
 	g.File = fn
-	var b []byte
-	for i := 0; i < 3; i++ {
-		b, err = ioutil.ReadFile(fn)
-		if err == nil {
-			break
-		}
-		time.Sleep(100 * time.Millisecond)
+	g.cache.reset(cachePath(fn))
+	c, err := config.LoadVerified(fn, g.Verifier)
+	if errors.Is(err, ErrConfigNotFound) && optional {
+		g.Logger.Println("running without configuration, waiting for", fn)
+		g.conf.ConfigOptional = true
+		return nil
 	}
 	if err != nil {
 		return err
 	}
-	err = yaml.Unmarshal(b, &g.conf)
-	if err != nil {
-		return err
+	if !c.EnabledIf.Enabled() {
+		g.Logger.Println("configuration is disabled by enabled_if:", c.EnabledIf)
+		return nil
+	}
+	g.conf.Config = *c
+	if len(c.AllowedCommands) > 0 {
+		fn, _ := config.AllowedCommandsPath()
+		g.Logger.Println("allowed_commands in the configuration is ignored, list commands in", fn)
 	}
-	if len(g.Args) == 0 && g.conf.Command != "" {
-		if runtime.GOOS == "windows" {
+	if g.NoCommand {
+		g.Args = nil
+	} else if len(g.Args) == 0 && g.conf.Command != "" {
+		if !g.allowed(g.conf.Command) {
+			g.Logger.Println("command is not allowed:", g.conf.Command)
+		} else if runtime.GOOS == "windows" {
 			g.Args = []string{"cmd", "/c", g.conf.Command}
 		} else {
 			g.Args = []string{"sh", "-c", g.conf.Command}
 		}
 	}
-	for _, t := range g.conf.Tasks {
+	if c.LatencyThreshold != "" {
+		g.conf.latencyThreshold, err = time.ParseDuration(c.LatencyThreshold)
+		if err != nil {
+			g.Logger.Println("invalid latency_threshold:", err)
+		}
+	}
+	var coverageTTL time.Duration
+	if c.CoverageTTL != "" {
+		coverageTTL, err = time.ParseDuration(c.CoverageTTL)
+		if err != nil {
+			g.Logger.Println("invalid coverage_ttl:", err)
+		}
+	}
+	g.coverage.setTTL(coverageTTL)
+	var perr error
+	for i, ct := range c.Tasks {
+		if !ct.EnabledIf.Enabled() {
+			g.Logger.Println("task", ct.Match, "is disabled by enabled_if:", ct.EnabledIf)
+			continue
+		}
+		t := &task{Task: ct}
+		g.conf.Tasks = append(g.conf.Tasks, t)
+		if t.Root != "" {
+			if t.dir, err = filepath.Abs(t.Root); err != nil {
+				g.Logger.Println(err)
+			}
+		}
 		if t.Match == "" {
 			continue
 		}
-		t.mre, err = compilePattern(t.Match)
+		t.matcher.Match, err = watcher.CompilePatternIn(t.dir, t.Match)
 		if err != nil {
-			g.Logger.Println(err)
+			err = &ErrPatternInvalid{Task: i, Pattern: t.Match, Err: err}
+			// the first error is returned, and others are logged
+			if perr == nil {
+				perr = err
+			} else {
+				g.Logger.Println(err)
+			}
 			continue
 		}
 		if t.Ignore != "" {
-			t.ire, err = compilePattern(t.Ignore)
+			t.matcher.Ignore, err = watcher.CompilePatternIn(t.dir, t.Ignore)
 			if err != nil {
-				g.Logger.Println(err)
+				err = &ErrPatternInvalid{Task: i, Pattern: t.Ignore, Err: err}
+				if perr == nil {
+					perr = err
+				} else {
+					g.Logger.Println(err)
+				}
+			}
+		}
+		if t.Timeout == "" {
+			t.Timeout = g.conf.Timeout
+		}
+		if t.Timeout != "" {
+			t.timeout, err = time.ParseDuration(t.Timeout)
+			if err != nil {
+				g.Logger.Println("invalid command_timeout:", err)
+			}
+		}
+		if t.ProblemMatcher != nil {
+			t.problemRe, err = regexp.Compile(t.ProblemMatcher.Regexp)
+			if err != nil {
+				g.Logger.Println("invalid problem_matcher:", err)
+			}
+		}
+		if t.MinInterval != "" {
+			t.minInterval, err = time.ParseDuration(t.MinInterval)
+			if err != nil {
+				g.Logger.Println("invalid min_interval:", err)
+			}
+		}
+		if t.MaxOutput == "" {
+			t.MaxOutput = g.conf.MaxOutput
+		}
+		if t.MaxOutput != "" {
+			t.maxOutput, err = config.ParseSize(t.MaxOutput)
+			if err != nil {
+				g.Logger.Println("invalid max_output:", err)
 			}
-		} else {
-			t.ire = nil
 		}
 		for _, op := range t.Ops {
-			switch strings.ToUpper(op) {
-			case fsnotify.Create.String():
-				t.mops = t.mops | uint32(fsnotify.Create)
-			case fsnotify.Write.String():
-				t.mops = t.mops | uint32(fsnotify.Write)
-			case fsnotify.Remove.String():
-				t.mops = t.mops | uint32(fsnotify.Remove)
-			case fsnotify.Rename.String():
-				t.mops = t.mops | uint32(fsnotify.Rename)
-			case fsnotify.Chmod.String():
-				t.mops = t.mops | uint32(fsnotify.Chmod)
-			default:
+			if o, ok := watcher.ParseOp(op); ok {
+				t.matcher.Ops |= o
+			} else {
 				g.Logger.Printf("unknow operation %v", op)
 			}
 		}
 	}
-	return nil
+	g.loadDeps()
+	sort.SliceStable(g.conf.Tasks, func(i, j int) bool {
+		return g.conf.Tasks[i].Priority > g.conf.Tasks[j].Priority
+	})
+	return perr
+}
+
+// watched return true if path is in the current directory or roots of tasks
+func (g *Goemon) watched(path string) bool {
+	dirs := g.roots()
+	if cwd, err := filepath.Abs("."); err == nil {
+		dirs = append(dirs, cwd)
+	}
+	for _, dir := range dirs {
+		if within(dir, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// within return true if path is dir or in dir
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || filepath.IsAbs(rel) {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// roots return root directories of tasks
+func (g *Goemon) roots() []string {
+	var dirs []string
+	dup := map[string]bool{}
+	for _, t := range g.conf.Tasks {
+		if t.dir != "" && !dup[t.dir] {
+			dup[t.dir] = true
+			dirs = append(dirs, t.dir)
+		}
+	}
+	return dirs
+}
+
+// Load read the configuration file. Invalid patterns are reported as
+// *ErrPatternInvalid but the other tasks are still loaded.
+func (g *Goemon) Load() error {
+	return g.load()
+}
+
+// Watch watch files and dispatch tasks until the configuration file is
+// changed.
+func (g *Goemon) Watch() error {
+	return g.watch()
 }
 
 // Run start tasks
 func (g *Goemon) Run() *Goemon {
+	defer g.recoverCrash()
 	err := g.load()
 	if err != nil {
 		g.Logger.Println(err)
 	}
+	g.setLoadError(err)
+	if err = g.startSync(); err != nil {
+		g.Logger.Println(err)
+	}
+
+	g.AddEventSource(g.manual)
+	g.AddEventSource(g.webhook)
+	g.startPollers()
+	g.startReloaders()
+	g.startEventSources()
+	go func() {
+		defer g.recoverCrash()
+		g.startCached()
+	}()
+	if g.NoCommand {
+		g.Logger.Println("running in watch-only mode, no command is supervised")
+	} else {
+		g.startProcesses()
+	}
+	if len(g.Args) == 0 {
+		g.notify("READY=1")
+	}
 
 	go func() {
+		defer g.recoverCrash()
 		g.Logger.Println("loading", g.File)
 		for {
 			err := g.watch()
@@ -331,15 +715,24 @@ func (g *Goemon) Run() *Goemon {
 				time.Sleep(time.Second)
 			}
 			g.Logger.Println("reloading", g.File)
+			g.notify("RELOADING=1")
 			err = g.load()
 			if err != nil {
 				g.Logger.Println(err)
 				time.Sleep(time.Second)
 			}
+			g.setLoadError(err)
+			g.notify("READY=1")
+			if err = g.startSync(); err != nil {
+				g.Logger.Println(err)
+			}
+			g.startPollers()
+			g.startReloaders()
 		}
 	}()
 
 	go func() {
+		defer g.recoverCrash()
 		g.Logger.Println("starting livereload")
 		for {
 			err := g.livereload()
@@ -374,23 +767,34 @@ func (g *Goemon) Run() *Goemon {
 				g.Logger.Println("restarting command")
 			case <-sig:
 				g.terminate(nil)
+				console.Restore()
 				os.Exit(0)
 			}
 		}
+	} else if len(g.procs) > 0 {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		g.terminate(nil)
+		console.Restore()
+		os.Exit(0)
 	}
 	return g
 }
 
 // Terminate stop goemon server
 func (g *Goemon) Terminate() {
-	if g.lrc != nil {
-		g.lrc.Close()
+	if g.reloader != nil {
+		g.reloader.Close()
+	}
+	g.reloadMutex.Lock()
+	for _, s := range g.reloaders {
+		s.Close()
 	}
+	g.reloadMutex.Unlock()
 	if g.fsw != nil {
 		g.fsw.Close()
 	}
-	if g.cmd.Process != nil {
-		g.terminate(nil)
-	}
+	g.terminate(nil)
 	g.Logger.Println("goemon terminated")
 }
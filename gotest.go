@@ -0,0 +1,170 @@
+package goemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mattn/goemon/commands"
+)
+
+// testFailures remember tests which failed recently for :gotest. Tests of
+// the package are empty when the package failed without failed tests like
+// build errors.
+type testFailures struct {
+	mutex    sync.Mutex
+	packages map[string][]string
+}
+
+// testEvent is the output of go test -json
+type testEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Output  string
+}
+
+// gotest is :gotest [-narrow] [-cover] [packages...]. It run packages which
+// failed recently at first, then run all packages if they passed. With
+// -narrow, only tests which failed are run at first. With -cover, only tests
+// which cover the changed file are run.
+func (g *Goemon) gotest(ctx *commands.Context, args ...string) error {
+	narrow, cover := false, false
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch args[0] {
+		case "-narrow":
+			narrow = true
+		case "-cover":
+			cover = true
+		default:
+			return fmt.Errorf(":gotest doesn't know %v", args[0])
+		}
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		args = []string{"./..."}
+	}
+	if cover && strings.HasSuffix(ctx.File, ".go") {
+		dir, err := filepath.Abs(ctx.Path("."))
+		if err != nil {
+			return err
+		}
+		// refresh after tests of this run finished, so they don't compete
+		defer g.coverage.schedule(dir, args, g.Logger)
+		if selected := g.coverage.selectTests(dir, ctx.File); selected != nil {
+			args = selected
+		}
+	}
+	if failed := g.failures.runArgs(narrow); failed != nil {
+		g.Logger.Println("running failed tests first")
+		if err := g.runGoTest(ctx, failed); err != nil {
+			return err
+		}
+	}
+	return g.runGoTest(ctx, args)
+}
+
+func (g *Goemon) runGoTest(ctx *commands.Context, args []string) error {
+	cmd, err := g.command("go", append([]string{"test", "-json"}, args...)...)
+	if err != nil {
+		return err
+	}
+	cmd.Dir = ctx.Dir
+	cmd.Stderr = ctx.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	// don't run in parallel with tests to refresh the coverage map
+	g.coverage.running.Lock()
+	defer g.coverage.running.Unlock()
+	g.Logger.Println("executing go test", strings.Join(args, " "))
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	results := map[string][]string{}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e testEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// build errors of old go are not JSON
+			fmt.Fprintln(ctx.Stdout, scanner.Text())
+			continue
+		}
+		if e.Output != "" {
+			fmt.Fprint(ctx.Stdout, e.Output)
+		}
+		if e.Package == "" {
+			continue
+		}
+		switch {
+		case e.Action == "fail" && e.Test != "":
+			name := strings.SplitN(e.Test, "/", 2)[0]
+			results[e.Package] = appendUnique(results[e.Package], name)
+		case e.Action == "fail":
+			if _, ok := results[e.Package]; !ok {
+				results[e.Package] = []string{}
+			}
+		case e.Action == "pass" && e.Test == "", e.Action == "skip" && e.Test == "":
+			results[e.Package] = nil
+		}
+	}
+	g.failures.update(results)
+	return cmd.Wait()
+}
+
+func appendUnique(a []string, s string) []string {
+	for _, v := range a {
+		if v == s {
+			return a
+		}
+	}
+	return append(a, s)
+}
+
+// update record failures of packages. Packages which passed are removed.
+func (f *testFailures) update(results map[string][]string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.packages == nil {
+		f.packages = map[string][]string{}
+	}
+	for pkg, tests := range results {
+		if tests == nil {
+			delete(f.packages, pkg)
+		} else {
+			f.packages[pkg] = tests
+		}
+	}
+}
+
+// runArgs return arguments of go test to run failed packages, or nil if no
+// packages failed.
+func (f *testFailures) runArgs(narrow bool) []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.packages) == 0 {
+		return nil
+	}
+	var pkgs, tests []string
+	all := false
+	for pkg, t := range f.packages {
+		pkgs = append(pkgs, pkg)
+		if len(t) == 0 {
+			all = true
+		}
+		tests = append(tests, t...)
+	}
+	sort.Strings(pkgs)
+	sort.Strings(tests)
+	if !narrow || all || len(tests) == 0 {
+		return pkgs
+	}
+	return append([]string{"-run", "^(" + strings.Join(tests, "|") + ")$"}, pkgs...)
+}
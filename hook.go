@@ -0,0 +1,74 @@
+package goemon
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const hookMarker = "# installed by goemon"
+
+// Hooks are names of git hooks which goemon install by default
+var Hooks = []string{"pre-commit", "post-merge"}
+
+// InstallHooks install git hooks which send changed files to running goemon.
+// Existing hooks which are not installed by goemon are not overwritten.
+func InstallHooks(hooks ...string) error {
+	if len(hooks) == 0 {
+		hooks = Hooks
+	}
+	b, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return fmt.Errorf("not a git repository: %v", err)
+	}
+	dir := strings.TrimSpace(string(b))
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, hook := range hooks {
+		if _, err := hookFilesArgs(hook); err != nil {
+			return err
+		}
+		fn := filepath.Join(dir, hook)
+		if b, err := ioutil.ReadFile(fn); err == nil && !strings.Contains(string(b), hookMarker) {
+			return fmt.Errorf("%s already exists", fn)
+		}
+		script := "#!/bin/sh\n" + hookMarker + "\ngoemon hook run " + hook + " || true\n"
+		if err = ioutil.WriteFile(fn, []byte(script), 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hookFilesArgs(hook string) ([]string, error) {
+	switch hook {
+	case "pre-commit":
+		return []string{"diff", "--cached", "--name-only", "--diff-filter=ACMR"}, nil
+	case "post-merge":
+		return []string{"diff", "--name-only", "--diff-filter=ACMR", "ORIG_HEAD", "HEAD"}, nil
+	}
+	return nil, fmt.Errorf("unsupported hook: %v", hook)
+}
+
+// HookFiles return files changed in the git hook
+func HookFiles(hook string) ([]string, error) {
+	args, err := hookFilesArgs(hook)
+	if err != nil {
+		return nil, err
+	}
+	b, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,67 @@
+package goemon
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of recent runs which percentiles are
+// computed from
+const latencySamples = 1000
+
+// latency is latency from events to starts and completions of tasks over
+// the session. It includes debounce, throttling and waiting for other tasks.
+// Only latencySamples recent runs are kept in the ring.
+type latency struct {
+	mutex sync.Mutex
+	start []time.Duration
+	done  []time.Duration
+	runs  int
+}
+
+func (l *latency) add(start, done time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.done) < latencySamples {
+		l.start = append(l.start, start)
+		l.done = append(l.done, done)
+	} else {
+		l.start[l.runs%latencySamples] = start
+		l.done[l.runs%latencySamples] = done
+	}
+	l.runs++
+}
+
+// String return percentiles of recent runs like "start p50=10ms p95=50ms,
+// done p50=1s p95=3s in 12 runs". It returns empty string without runs.
+func (l *latency) String() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.done) == 0 {
+		return ""
+	}
+	p := func(durations []time.Duration) string {
+		sorted := append([]time.Duration(nil), durations...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+		return fmt.Sprintf("p50=%v p95=%v",
+			percentile(sorted, 50).Round(time.Millisecond),
+			percentile(sorted, 95).Round(time.Millisecond))
+	}
+	return fmt.Sprintf("start %s, done %s in %d runs", p(l.start), p(l.done), l.runs)
+}
+
+// measure record latency of the run of the task fired by the event at, and
+// warn if it is beyond latency_threshold. started is the time when the run
+// started after waiting for other tasks.
+func (g *Goemon) measure(t *task, at, started time.Time) {
+	start, done := started.Sub(at), time.Since(at)
+	if threshold := g.conf.latencyThreshold; threshold > 0 && done > threshold {
+		g.Logger.Printf("feedback of %s took %v (%v before start), beyond latency_threshold %v",
+			t.Match, done.Round(time.Millisecond), start.Round(time.Millisecond), threshold)
+	}
+	g.latency.add(start, done)
+}
@@ -0,0 +1,197 @@
+package goemon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mattn/goemon/commands"
+	"github.com/mattn/goemon/reload"
+)
+
+// findingRe match lines of findings like "main.go:10:2: message"
+var findingRe = regexp.MustCompile(`(?m)^\S+\.go:\d+(?::\d+)?:`)
+
+// linter return the command line of the linter which is installed
+func linter() ([]string, error) {
+	if _, err := exec.LookPath("golangci-lint"); err == nil {
+		return []string{"golangci-lint", "run"}, nil
+	}
+	if _, err := exec.LookPath("staticcheck"); err == nil {
+		return []string{"staticcheck"}, nil
+	}
+	return nil, errors.New(":lint requires golangci-lint or staticcheck")
+}
+
+// lint is :lint [linter...]. It run the linter for the package of the
+// changed file, or all packages. Results are cached with hash of files of
+// the package, its dependencies and configs of linters, so the linter doesn't
+// run for the package which is not changed. Findings are shown in browsers too.
+func (g *Goemon) lint(ctx *commands.Context, args ...string) error {
+	if len(args) == 0 {
+		var err error
+		if args, err = linter(); err != nil {
+			return err
+		}
+	}
+	dir, err := filepath.Abs(ctx.Path("."))
+	if err != nil {
+		return err
+	}
+	pkg, recursive := "./...", true
+	if strings.HasSuffix(ctx.File, ".go") {
+		rel, err := filepath.Rel(dir, filepath.Dir(ctx.File))
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			pkg, recursive = "./"+filepath.ToSlash(rel), false
+		}
+	}
+
+	key := "lint\x00" + dir + "\x00" + pkg + "\x00" + strings.Join(args, "\x00")
+	sum := sha256.Sum256([]byte(key))
+	key = hex.EncodeToString(sum[:])
+	hash := g.lintHash(dir, pkg, recursive)
+
+	var out string
+	if cached := g.cache.get(key); hash != "" && strings.HasPrefix(cached, hash+"\n") {
+		g.Logger.Println("using cached results of", pkg)
+		out = strings.TrimPrefix(cached, hash+"\n")
+		io.WriteString(ctx.Stdout, out)
+	} else {
+		var buf bytes.Buffer
+		cmd, err := g.command(args[0], append(args[1:], pkg)...)
+		if err != nil {
+			return err
+		}
+		cmd.Dir = dir
+		cmd.Stdout = io.MultiWriter(ctx.Stdout, &buf)
+		cmd.Stderr = io.MultiWriter(ctx.Stderr, &buf)
+		g.Logger.Println("executing", strings.Join(cmd.Args, " "))
+		err = cmd.Run()
+		if _, ok := err.(*exec.ExitError); err != nil && !ok {
+			return err
+		}
+		out = buf.String()
+		if err == nil {
+			out = ""
+		}
+		if hash != "" {
+			if err := g.cache.put(key, hash+"\n"+out); err != nil {
+				g.Logger.Println("failed to save cache:", err)
+			}
+		}
+	}
+
+	if out == "" {
+		return nil
+	}
+	n := len(findingRe.FindAllString(out, -1))
+	message := fmt.Sprintf("lint: %d findings in %s", n, pkg)
+	g.notify("STATUS=" + message)
+	g.alert(message + "\n\n" + out)
+	return errors.New(message)
+}
+
+// alert show message in browsers
+func (g *Goemon) alert(message string) {
+	if r, ok := g.reloaderFor("").(reload.Alerter); ok {
+		r.Alert(message)
+	}
+}
+
+// lintConfigs are configuration files of linters which change results
+var lintConfigs = []string{
+	".golangci.yml", ".golangci.yaml", ".golangci.toml", ".golangci.json",
+	"staticcheck.conf", "go.mod", "go.sum",
+}
+
+// lintHash return hash of inputs of the linter for pkg in dir: go files of
+// the package and packages which it depends on in dir, configuration files
+// of linters, and go.mod and go.sum for other dependencies. It returns empty
+// string if the hash can't be computed.
+func (g *Goemon) lintHash(dir, pkg string, recursive bool) string {
+	pkgDir := dir
+	if !recursive {
+		pkgDir = filepath.Join(dir, filepath.FromSlash(pkg))
+	}
+	h := sha256.New()
+	// configuration files are searched from the package up to dir
+	for d := pkgDir; within(dir, d); d = filepath.Dir(d) {
+		for _, name := range lintConfigs {
+			if b, err := ioutil.ReadFile(filepath.Join(d, name)); err == nil {
+				fmt.Fprintf(h, "%s\x00%d\x00", filepath.Join(d, name), len(b))
+				h.Write(b)
+			}
+		}
+		if d == dir {
+			break
+		}
+	}
+
+	// only files of the package are hashed if go list is not available
+	dirs := []string{pkgDir}
+	if cmd, err := g.command("go", "list", "-deps", "-f", "{{if not .Standard}}{{.Dir}}{{end}}", pkg); err == nil {
+		cmd.Dir = dir
+		if b, err := cmd.Output(); err == nil {
+			dirs = strings.Fields(string(b))
+			recursive = false
+		}
+	}
+	sort.Strings(dirs)
+	for _, d := range dirs {
+		if !within(dir, d) {
+			// dependencies out of dir are fixed by go.sum
+			continue
+		}
+		sum := goFilesHash(d, recursive)
+		if sum == "" {
+			return ""
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00", d, sum)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// goFilesHash return hash of go files in dir. It returns empty string if the
+// hash can't be computed.
+func goFilesHash(dir string, recursive bool) string {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if info == nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && (!recursive || info.Name() == ".git" || info.Name() == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(path, ".go") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return ""
+	}
+	sort.Strings(files)
+	h := sha256.New()
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return ""
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", file, len(b))
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,74 @@
+package goemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/mattn/goemon/commands"
+)
+
+// migrateArgs return command line to run pending migrations with goose or
+// golang-migrate.
+func (g *Goemon) migrateArgs(dir string) ([]string, error) {
+	mc := g.conf.Migrate
+	if dir == "" {
+		dir = mc.Dir
+	}
+	if dir == "" {
+		dir = "migrations"
+	}
+	database := os.ExpandEnv(mc.Database)
+	if database == "" {
+		database = os.Getenv("DATABASE_URL")
+	}
+	if database == "" {
+		return nil, fmt.Errorf("database for :migrate is not specified")
+	}
+	tool := mc.Tool
+	if tool == "" {
+		for _, name := range []string{"goose", "migrate"} {
+			if _, err := exec.LookPath(name); err == nil {
+				tool = name
+				break
+			}
+		}
+	}
+	switch tool {
+	case "goose":
+		if mc.Driver == "" {
+			return nil, fmt.Errorf("driver for goose is not specified")
+		}
+		return []string{"goose", "-dir", dir, mc.Driver, database, "up"}, nil
+	case "migrate", "golang-migrate":
+		return []string{"migrate", "-path", dir, "-database", database, "up"}, nil
+	case "":
+		return nil, fmt.Errorf("goose or migrate is not found")
+	}
+	return nil, fmt.Errorf("unknown migration tool: %v", tool)
+}
+
+// migrate is :migrate [dir]. It run pending migrations in the directory of
+// the task.
+func (g *Goemon) migrate(ctx *commands.Context, args ...string) error {
+	dir := ""
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	args, err := g.migrateArgs(dir)
+	if err != nil {
+		return err
+	}
+	g.Logger.Println("migrating", dir)
+	cmd, err := g.command(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	cmd.Dir = ctx.Dir
+	cmd.Stdout = ctx.Stdout
+	cmd.Stderr = ctx.Stderr
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("failed to migrate: %v", err)
+	}
+	return nil
+}
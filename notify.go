@@ -0,0 +1,31 @@
+package goemon
+
+import (
+	"net"
+	"os"
+)
+
+// sdNotify send state to systemd when goemon is run as the service of
+// Type=notify. It does nothing without NOTIFY_SOCKET.
+func sdNotify(state string) error {
+	name := os.Getenv("NOTIFY_SOCKET")
+	if name == "" {
+		return nil
+	}
+	if name[0] == '@' {
+		name = "\x00" + name[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+func (g *Goemon) notify(state string) {
+	if err := sdNotify(state); err != nil {
+		g.Logger.Println("failed to notify:", err)
+	}
+}
@@ -0,0 +1,122 @@
+package goemon
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattn/goemon/console"
+)
+
+// groupBuffer is buffer shared by stdout and stderr of commands
+type groupBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *groupBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+// Once run each task which match some files once with the first matched
+// file, then return error if some tasks failed. It is useful for CI.
+// Output of each task is buffered and printed grouped with the markers of
+// GitHub Actions. Output of failed tasks is not folded.
+func (g *Goemon) Once() error {
+	if err := g.load(); err != nil {
+		return err
+	}
+	files, err := g.onceFiles()
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	failed := 0
+	for _, t := range g.conf.Tasks {
+		matched := ""
+		if !strings.HasPrefix(t.Match, ":") && t.matcher.Match != nil {
+			for _, file := range files {
+				if t.match(file) {
+					matched = file
+					break
+				}
+			}
+		}
+		if matched == "" {
+			continue
+		}
+
+		buf := &groupBuffer{}
+		t.out = buf
+		wg.Add(1)
+		go func(t *task, file string) {
+			defer wg.Done()
+			defer g.recoverCrash()
+			start := time.Now()
+			ok := g.run(t, file)
+			elapsed := time.Since(start).Round(time.Millisecond)
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if !ok {
+				failed++
+			}
+			g.printGroup(t.Match, ok, elapsed, buf.buf.Bytes())
+		}(t, matched)
+	}
+	wg.Wait()
+
+	if failed > 0 {
+		return fmt.Errorf("%d tasks failed", failed)
+	}
+	return nil
+}
+
+// onceFiles return files under the current directory and roots of tasks
+func (g *Goemon) onceFiles() ([]string, error) {
+	files, err := listFiles(".")
+	if err != nil {
+		return nil, err
+	}
+	cwd, err := filepath.Abs(".")
+	if err != nil {
+		return nil, err
+	}
+	for _, root := range g.roots() {
+		if rel, err := filepath.Rel(cwd, root); err == nil && !strings.HasPrefix(rel, "..") {
+			continue
+		}
+		more, err := listFiles(root)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, more...)
+	}
+	return files, nil
+}
+
+func (g *Goemon) printGroup(name string, ok bool, elapsed time.Duration, b []byte) {
+	w := console.Stdout
+	if g.output != nil {
+		w = g.output
+	}
+	if ok {
+		fmt.Fprintf(w, "::group::%s (%v)\n", name, elapsed)
+	} else {
+		fmt.Fprintf(w, "::error::%s failed (%v)\n", name, elapsed)
+	}
+	w.Write(b)
+	if len(b) > 0 && b[len(b)-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+	if ok {
+		fmt.Fprintln(w, "::endgroup::")
+	}
+}
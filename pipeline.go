@@ -0,0 +1,134 @@
+package goemon
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/mattn/goemon/commands"
+	"github.com/mattn/goemon/runner"
+)
+
+// isPipeline return true if the command contains internal commands connected
+// with pipes. Pipelines which consist of only external commands are run by
+// shell as before.
+func isPipeline(command string) bool {
+	stages := splitPipeline(command)
+	if len(stages) < 2 {
+		return false
+	}
+	for _, stage := range stages {
+		if strings.HasPrefix(stage, ":") {
+			return true
+		}
+	}
+	return false
+}
+
+// splitPipeline split command at " | " which is not quoted by single or
+// double quotes, or escaped with backslash, like shell.
+func splitPipeline(command string) []string {
+	var stages []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case strings.HasPrefix(command[i:], " | "):
+			stages = append(stages, strings.TrimSpace(command[start:i]))
+			start = i + 3
+			i += 2
+		}
+	}
+	return append(stages, strings.TrimSpace(command[start:]))
+}
+
+// pipeline run stages concurrently connecting output of each stage into
+// input of next stage. Output of the last stage is written into stdout.
+// command_timeout is applied to each external stage, and max_output to
+// output of the pipeline.
+func (g *Goemon) pipeline(t *task, stages []string, file string) bool {
+	g.Logger.Println("executing", strings.Join(stages, " | "))
+
+	stdout, stderr := t.stdout(), t.stderr()
+	if t.maxOutput > 0 {
+		stdout, stderr = runner.NewLimitWriters(stdout, stderr, t.maxOutput)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, len(stages))
+	var r io.Reader = strings.NewReader("")
+	for i, stage := range stages {
+		w := stdout
+		var next io.Reader
+		if i < len(stages)-1 {
+			pr, pw := io.Pipe()
+			w, next = pw, pr
+		}
+		wg.Add(1)
+		go func(i int, stage string, r io.Reader, w io.Writer) {
+			defer wg.Done()
+			defer g.recoverCrash()
+			err := g.stage(t, stage, file, r, w, stderr)
+			if pr, ok := r.(*io.PipeReader); ok {
+				// unblock the previous stage if this stage didn't read all
+				pr.Close()
+			}
+			if pw, ok := w.(*io.PipeWriter); ok {
+				pw.CloseWithError(err)
+			}
+			if err == io.ErrClosedPipe && i < len(stages)-1 {
+				// next stage exited without reading all of output
+				err = nil
+			}
+			errs[i] = err
+		}(i, stage, r, w)
+		r = next
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			g.Logger.Printf("%v: %v", stages[i], err)
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Goemon) stage(t *task, stage, file string, r io.Reader, w, stderr io.Writer) error {
+	if strings.HasPrefix(stage, ":") {
+		ctx := &commands.Context{
+			File:    file,
+			Dir:     t.dir,
+			Stdin:   r,
+			Stdout:  w,
+			Stderr:  stderr,
+			Logger:  g.Logger,
+			Allowed: g.allowedArgs,
+		}
+		return g.Commands.Run(ctx, t.scope(stage))
+	}
+
+	if !g.allowed(stage) {
+		return fmt.Errorf("command is not allowed: %v", stage)
+	}
+	cmd := runner.Shell(stage)
+	cmd.Dir = t.dir
+	cmd.Stdin = r
+	cmd.Stdout = w
+	cmd.Stderr = stderr
+	return runner.Run(cmd, t.timeout)
+}
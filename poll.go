@@ -0,0 +1,175 @@
+package goemon
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/mattn/goemon/runner"
+)
+
+const defaultWatchInterval = 5 * time.Second
+
+// pollSource is EventSource which poll URL or output of command of the task,
+// and emit event when it is changed.
+type pollSource struct {
+	g        *Goemon
+	t        *task
+	interval time.Duration
+	client   *http.Client
+	etag     string
+	last     string
+	ch       chan Event
+	done     chan struct{}
+}
+
+func (g *Goemon) startPollers() {
+	for _, p := range g.pollers {
+		p.Close()
+	}
+	g.pollers = nil
+	for _, t := range g.conf.Tasks {
+		if t.WatchURL == "" && t.WatchCmd == "" {
+			continue
+		}
+		interval := defaultWatchInterval
+		if t.Interval != "" {
+			d, err := time.ParseDuration(t.Interval)
+			if err != nil {
+				g.Logger.Println("invalid watch_interval:", err)
+			} else {
+				interval = d
+			}
+		}
+		p := newPollSource(g, t, interval)
+		go p.loop()
+		g.pollers = append(g.pollers, p)
+		g.AddEventSource(p)
+	}
+}
+
+func newPollSource(g *Goemon, t *task, interval time.Duration) *pollSource {
+	// requests must finish before next poll. The timeout is at least one
+	// second for slow servers.
+	timeout := interval
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+	return &pollSource{
+		g:        g,
+		t:        t,
+		interval: interval,
+		client:   &http.Client{Timeout: timeout},
+		ch:       make(chan Event),
+		done:     make(chan struct{}),
+	}
+}
+
+func (p *pollSource) Events() <-chan Event {
+	return p.ch
+}
+
+func (p *pollSource) Close() error {
+	close(p.done)
+	return nil
+}
+
+func (p *pollSource) loop() {
+	defer close(p.ch)
+	name := p.t.watchName()
+	first := true
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		changed, err := p.poll()
+		if err != nil {
+			p.g.Logger.Println("failed to poll", name, err)
+		} else if changed && !first {
+			select {
+			case p.ch <- NewEvent(name, Write):
+			case <-p.done:
+				return
+			}
+		}
+		if err == nil {
+			first = false
+		}
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+// poll return true if the resource is changed since the last poll
+func (p *pollSource) poll() (bool, error) {
+	var sum string
+	var err error
+	if p.t.WatchURL != "" {
+		sum, err = p.pollURL()
+	} else {
+		sum, err = p.pollCmd()
+	}
+	if err != nil || sum == "" {
+		return false, err
+	}
+	changed := sum != p.last
+	p.last = sum
+	return changed, nil
+}
+
+func (p *pollSource) pollURL() (string, error) {
+	req, err := http.NewRequest(http.MethodGet, p.t.WatchURL, nil)
+	if err != nil {
+		return "", err
+	}
+	if p.etag != "" {
+		req.Header.Set("If-None-Match", p.etag)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %v", resp.Status)
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		p.etag = etag
+		return etag, nil
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (p *pollSource) pollCmd() (string, error) {
+	if !p.g.allowed(p.t.WatchCmd) {
+		return "", fmt.Errorf("command is not allowed: %v", p.t.WatchCmd)
+	}
+	cmd := runner.Shell(p.t.WatchCmd)
+	cmd.Dir = p.t.dir
+	b, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// watchName return name of event for watch_url or watch_cmd
+func (t *task) watchName() string {
+	if t.WatchURL != "" {
+		return t.WatchURL
+	}
+	return t.WatchCmd
+}
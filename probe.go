@@ -0,0 +1,71 @@
+package goemon
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/goemon/runner"
+)
+
+const (
+	defaultProbeInterval = time.Second
+	probeTimeout         = 5 * time.Second
+)
+
+// probe check readiness of the process until it is ready or exited
+func (g *Goemon) probe(p *process) {
+	if p.probe == nil {
+		atomic.StoreInt32(&p.ready, 1)
+		return
+	}
+	interval := defaultProbeInterval
+	if p.probe.Interval != "" {
+		d, err := time.ParseDuration(p.probe.Interval)
+		if err != nil {
+			g.Logger.Println("invalid interval of ready:", err)
+		} else {
+			interval = d
+		}
+	}
+	for atomic.LoadInt32(&p.running) == 1 {
+		err := g.check(p)
+		if err == nil {
+			g.Logger.Println("process", p.name, "is ready")
+			atomic.StoreInt32(&p.ready, 1)
+			return
+		}
+		time.Sleep(interval)
+	}
+}
+
+// check run the probe of the process once
+func (g *Goemon) check(p *process) error {
+	switch {
+	case p.probe.HTTP != "":
+		client := &http.Client{Timeout: probeTimeout}
+		resp, err := client.Get(p.probe.HTTP)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("%s: %s", p.probe.HTTP, resp.Status)
+		}
+		return nil
+	case p.probe.TCP != "":
+		conn, err := net.DialTimeout("tcp", p.probe.TCP, probeTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case p.probe.Command != "":
+		if !g.allowed(p.probe.Command) {
+			return fmt.Errorf("command is not allowed: %v", p.probe.Command)
+		}
+		return runner.Run(runner.Shell(p.probe.Command), probeTimeout)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package goemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Problem is the diagnostic parsed from output of the task with
+// problem_matcher
+type Problem struct {
+	Task     string `json:"task"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity,omitempty"`
+	Message  string `json:"message"`
+}
+
+// String return the problem like "main.go:1:2: error: message"
+func (p Problem) String() string {
+	s := p.File + ":" + strconv.Itoa(p.Line)
+	if p.Column > 0 {
+		s += ":" + strconv.Itoa(p.Column)
+	}
+	if p.Severity != "" {
+		s += ": " + p.Severity
+	}
+	return s + ": " + p.Message
+}
+
+// problems is problems of the last run of each task
+type problems struct {
+	mutex sync.Mutex
+	tasks map[*task][]Problem
+}
+
+func (ps *problems) set(t *task, problems []Problem) {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	if ps.tasks == nil {
+		ps.tasks = map[*task][]Problem{}
+	}
+	ps.tasks[t] = problems
+}
+
+func (ps *problems) get(t *task) []Problem {
+	ps.mutex.Lock()
+	defer ps.mutex.Unlock()
+	return ps.tasks[t]
+}
+
+// parseProblems parse output of the task with problem_matcher. Relative file
+// names are resolved from the directory of the task.
+func (t *task) parseProblems(out string) []Problem {
+	m := t.ProblemMatcher
+	group := func(sm []string, i int) string {
+		if i <= 0 || i >= len(sm) {
+			return ""
+		}
+		return strings.TrimSpace(sm[i])
+	}
+	var problems []Problem
+	for _, line := range strings.Split(strings.Replace(out, "\r", "", -1), "\n") {
+		sm := t.problemRe.FindStringSubmatch(line)
+		if sm == nil {
+			continue
+		}
+		p := Problem{
+			Task:     t.Match,
+			File:     group(sm, m.File),
+			Severity: group(sm, m.Severity),
+			Message:  group(sm, m.Message),
+		}
+		p.Line, _ = strconv.Atoi(group(sm, m.Line))
+		p.Column, _ = strconv.Atoi(group(sm, m.Column))
+		if p.File == "" {
+			continue
+		}
+		if !filepath.IsAbs(p.File) {
+			if fn, err := filepath.Abs(filepath.Join(t.dir, p.File)); err == nil {
+				p.File = fn
+			}
+		}
+		p.File = filepath.ToSlash(p.File)
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// reportProblems parse captured output of the task, and show problems in
+// browsers
+func (g *Goemon) reportProblems(t *task) {
+	t.capture.mutex.Lock()
+	out := t.capture.buf.String()
+	t.capture.mutex.Unlock()
+	problems := t.parseProblems(out)
+	g.problems.set(t, problems)
+	if len(problems) == 0 {
+		return
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d problems in %s\n\n", len(problems), t.Match)
+	for _, p := range problems {
+		sb.WriteString(p.String() + "\n")
+	}
+	g.Logger.Printf("%d problems in %s", len(problems), t.Match)
+	g.alert(sb.String())
+}
+
+// Problems return problems found in the last run of each task
+func (g *Goemon) Problems() []Problem {
+	problems := []Problem{}
+	for _, t := range g.conf.Tasks {
+		problems = append(problems, g.problems.get(t)...)
+	}
+	return problems
+}
+
+func (g *Goemon) serveProblems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g.Problems())
+}
+
+// FetchProblems ask goemon running on the same machine with the
+// configuration problems found by tasks
+func (g *Goemon) FetchProblems() ([]Problem, error) {
+	resp, err := g.controlRequest(http.MethodGet, "problems", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %v", resp.Status)
+	}
+	var problems []Problem
+	if err = json.NewDecoder(resp.Body).Decode(&problems); err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
@@ -0,0 +1,203 @@
+package goemon
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/goemon/config"
+	"github.com/mattn/goemon/console"
+	"github.com/mattn/goemon/runner"
+)
+
+// process is the long-running process in processes of the configuration. It
+// is supervised with the command given by arguments.
+type process struct {
+	name    string
+	restart string
+	proc    *runner.Process
+	running int32
+	stopped int32
+	wake    chan struct{}
+
+	// ready is 1 while the process passes the probe. Processes which depend
+	// on it wait for it.
+	ready int32
+	probe *config.Probe
+	deps  []*process
+}
+
+// startProcesses start processes of the configuration. Processes are read
+// only when goemon is started.
+func (g *Goemon) startProcesses() {
+	names := make([]string, 0, len(g.conf.Processes))
+	for name := range g.conf.Processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := g.conf.Processes[name]
+		if !g.allowed(c.Command) {
+			g.Logger.Println("command is not allowed:", c.Command)
+			continue
+		}
+		p := &process{
+			name:    name,
+			restart: c.Restart,
+			probe:   c.Ready,
+			proc:    runner.NewProcess(runner.Shell(c.Command).Args, g.Logger),
+			wake:    make(chan struct{}, 1),
+		}
+		switch p.restart {
+		case "":
+			p.restart = "always"
+		case "always", "on-failure", "no":
+		default:
+			g.Logger.Printf("unknown restart %q of %s", p.restart, name)
+			p.restart = "always"
+		}
+		p.proc.Stdout = runner.NewPrefixWriter(console.Stdout, "["+name+"] ")
+		p.proc.Stderr = runner.NewPrefixWriter(console.Stderr, "["+name+"] ")
+		p.proc.OnStart = func() {
+			atomic.StoreInt32(&p.running, 1)
+			go g.probe(p)
+		}
+		g.procs = append(g.procs, p)
+	}
+
+	for _, p := range g.procs {
+		for _, name := range g.conf.Processes[p.name].DependsOn {
+			dep := g.process(name)
+			if dep == nil {
+				g.Logger.Printf("unknown process %q in depends_on of %s", name, p.name)
+				continue
+			}
+			p.deps = append(p.deps, dep)
+		}
+	}
+	for _, p := range g.procs {
+		if p.circular(p, map[*process]bool{}) {
+			g.Logger.Println("ignoring circular depends_on of", p.name)
+			p.deps = nil
+		}
+	}
+	for _, p := range g.procs {
+		go g.supervise(p)
+	}
+}
+
+// process return the process which has name
+func (g *Goemon) process(name string) *process {
+	for _, p := range g.procs {
+		if p.name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// circular return true if p depends on target
+func (p *process) circular(target *process, seen map[*process]bool) bool {
+	if seen[p] {
+		return false
+	}
+	seen[p] = true
+	for _, dep := range p.deps {
+		if dep == target || dep.circular(target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitDeps wait until processes which p depends on are ready
+func (g *Goemon) waitDeps(p *process) {
+	for _, dep := range p.deps {
+		if atomic.LoadInt32(&dep.ready) == 1 {
+			continue
+		}
+		g.Logger.Println("process", p.name, "is waiting for", dep.name)
+		for atomic.LoadInt32(&dep.ready) == 0 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// supervise run the process, and restart it when it is terminated by goemon
+// or exited by itself as restart allows.
+func (g *Goemon) supervise(p *process) {
+	defer g.recoverCrash()
+	g.Logger.Println("starting process", p.name)
+	for {
+		if atomic.LoadUint64(&g.tasks) > 0 {
+			time.Sleep(time.Second)
+			continue
+		}
+		g.waitDeps(p)
+		atomic.StoreInt32(&p.stopped, 0)
+		err := p.proc.Run()
+		atomic.StoreInt32(&p.running, 0)
+		atomic.StoreInt32(&p.ready, 0)
+		if err != nil {
+			g.Logger.Println(p.name+":", err)
+		}
+		if atomic.LoadInt32(&p.stopped) == 0 && !p.restartOnExit(err) {
+			g.Logger.Println("process", p.name, "exited")
+			if err == nil {
+				// completed successfully like migrations
+				atomic.StoreInt32(&p.ready, 1)
+			}
+			<-p.wake
+		} else if err != nil {
+			time.Sleep(time.Second)
+		}
+		g.Logger.Println("restarting process", p.name)
+	}
+}
+
+// restartOnExit return true if the process should be restarted when it
+// exited by itself with err.
+func (p *process) restartOnExit(err error) bool {
+	switch p.restart {
+	case "no":
+		return false
+	case "on-failure":
+		return err != nil
+	}
+	return true
+}
+
+// terminateProcesses send sig to processes which have names
+func (g *Goemon) terminateProcesses(sig os.Signal, names []string) error {
+	for _, name := range names {
+		found := false
+		for _, p := range g.procs {
+			if p.name == name {
+				found = true
+				if err := p.terminate(sig); err != nil {
+					return err
+				}
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown process: %v", name)
+		}
+	}
+	return nil
+}
+
+// terminate send sig to the process. The process is restarted by supervise
+// even if it has exited.
+func (p *process) terminate(sig os.Signal) error {
+	atomic.StoreInt32(&p.stopped, 1)
+	if atomic.LoadInt32(&p.running) == 0 {
+		select {
+		case p.wake <- struct{}{}:
+		default:
+		}
+		return nil
+	}
+	return p.proc.Terminate(sig)
+}
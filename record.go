@@ -0,0 +1,144 @@
+package goemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/goemon/watcher"
+)
+
+// recordedEvent is an event in the event stream which is stored as JSON
+// lines. Name is relative from working directory if possible.
+type recordedEvent struct {
+	Time time.Time `json:"time"`
+	Name string    `json:"name"`
+	Op   string    `json:"op"`
+}
+
+func (re *recordedEvent) event() (Event, error) {
+	event := Event{Path: filepath.FromSlash(re.Name)}
+	if !strings.HasPrefix(re.Name, ":") && !filepath.IsAbs(event.Path) {
+		if fn, err := filepath.Abs(event.Path); err == nil {
+			event.Path = fn
+		}
+	}
+	for _, s := range strings.Split(re.Op, "|") {
+		op, ok := watcher.ParseOp(s)
+		if !ok {
+			return event, fmt.Errorf("unknown operation %v", s)
+		}
+		event.Op |= op
+	}
+	return event, nil
+}
+
+func readEvents(r io.Reader) ([]recordedEvent, error) {
+	var events []recordedEvent
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var re recordedEvent
+		if err := json.Unmarshal([]byte(line), &re); err != nil {
+			return nil, err
+		}
+		events = append(events, re)
+	}
+	return events, scanner.Err()
+}
+
+func newRecordedEvent(event Event) recordedEvent {
+	name := event.Path
+	if cwd, err := os.Getwd(); err == nil && filepath.IsAbs(name) {
+		if rel, err := filepath.Rel(cwd, name); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+	}
+	t := event.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return recordedEvent{Time: t, Name: filepath.ToSlash(name), Op: event.Op.String()}
+}
+
+// Record write all dispatched events into w as JSON lines
+func (g *Goemon) Record(w io.Writer) {
+	g.recordMutex.Lock()
+	defer g.recordMutex.Unlock()
+	g.recorder = json.NewEncoder(w)
+}
+
+func (g *Goemon) record(event Event) {
+	g.recordMutex.Lock()
+	defer g.recordMutex.Unlock()
+	if g.recorder == nil {
+		return
+	}
+	if err := g.recorder.Encode(newRecordedEvent(event)); err != nil {
+		g.Logger.Println("failed to record event:", err)
+	}
+}
+
+// replaySource is EventSource which replay recorded events keeping the
+// intervals between them.
+type replaySource struct {
+	events []recordedEvent
+	ch     chan Event
+	done   chan struct{}
+}
+
+// NewReplaySource return EventSource which replay events recorded by Record
+func NewReplaySource(r io.Reader) (EventSource, error) {
+	events, err := readEvents(r)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		if _, err := events[i].event(); err != nil {
+			return nil, err
+		}
+	}
+	return &replaySource{events: events, done: make(chan struct{})}, nil
+}
+
+func (s *replaySource) Events() <-chan Event {
+	if s.ch != nil {
+		return s.ch
+	}
+	s.ch = make(chan Event)
+	go func() {
+		defer close(s.ch)
+		for i := range s.events {
+			if i > 0 {
+				if d := s.events[i].Time.Sub(s.events[i-1].Time); d > 0 {
+					select {
+					case <-time.After(d):
+					case <-s.done:
+						return
+					}
+				}
+			}
+			event, _ := s.events[i].event()
+			event.Time = time.Now()
+			select {
+			case s.ch <- event:
+			case <-s.done:
+				return
+			}
+		}
+	}()
+	return s.ch
+}
+
+func (s *replaySource) Close() error {
+	close(s.done)
+	return nil
+}
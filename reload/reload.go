@@ -0,0 +1,86 @@
+// Package reload serve livereload protocol for browsers
+package reload
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/omeid/livereload"
+)
+
+// Reloader notify clients that path is changed. Server is the default
+// implementation. Implement it to notify your own clients like IPC bridge of
+// desktop applications.
+type Reloader interface {
+	Reload(path string)
+}
+
+// Alerter is implemented by Reloader which can show messages to clients
+type Alerter interface {
+	Alert(message string)
+}
+
+var (
+	_ Reloader = (*Server)(nil)
+	_ Alerter  = (*Server)(nil)
+)
+
+// Server is livereload server. Other handlers can be mounted on the same
+// address with Handle.
+type Server struct {
+	lrs *livereload.Server
+	mux *http.ServeMux
+	l   net.Listener
+}
+
+// New create new Server
+func New() *Server {
+	s := &Server{
+		lrs: livereload.New("goemon"),
+		mux: http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/livereload.js", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/javascript")
+		_, err := w.Write([]byte(liveReloadScript))
+		if err != nil && s.l != nil {
+			s.l.Close()
+		}
+	})
+	s.mux.Handle("/livereload", s.lrs)
+	return s
+}
+
+// Handle register handler for pattern
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ListenAndServe listen addr and serve until Close is called
+func (s *Server) ListenAndServe(addr string) error {
+	var err error
+	s.l, err = net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer s.l.Close()
+	return http.Serve(s.l, s.mux)
+}
+
+// Reload tell browsers to reload path
+func (s *Server) Reload(path string) {
+	s.lrs.Reload(path, true)
+}
+
+// Alert show message in browsers
+func (s *Server) Alert(message string) {
+	s.lrs.Alert(message)
+}
+
+// Close stop the server
+func (s *Server) Close() error {
+	s.lrs.Close()
+	if s.l != nil {
+		return s.l.Close()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// limitWriter write up to n bytes into w. Rest of the output is discarded
+// with the notice which is written once when the output exceeds n.
+type limitWriter struct {
+	w     io.Writer
+	n     int64
+	mutex *sync.Mutex
+	count *int64
+}
+
+// NewLimitWriters return writers for stdout and stderr which share the limit
+// of n bytes.
+func NewLimitWriters(stdout, stderr io.Writer, n int64) (io.Writer, io.Writer) {
+	var mutex sync.Mutex
+	var count int64
+	return &limitWriter{w: stdout, n: n, mutex: &mutex, count: &count},
+		&limitWriter{w: stderr, n: n, mutex: &mutex, count: &count}
+}
+
+func (l *limitWriter) Write(b []byte) (int, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	rest := l.n - *l.count
+	*l.count += int64(len(b))
+	if int64(len(b)) <= rest {
+		return l.w.Write(b)
+	}
+	if rest < 0 {
+		// the notice is already written
+		return len(b), nil
+	}
+	if _, err := l.w.Write(b[:rest]); err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(l.w, "\n[goemon: output truncated after %d bytes]\n", l.n)
+	return len(b), nil
+}
+
+// prefixWriter write lines into w with prefix
+type prefixWriter struct {
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+	mutex  sync.Mutex
+}
+
+// NewPrefixWriter return writer which write each line into w with prefix.
+// Incomplete line is buffered until the newline is written.
+func NewPrefixWriter(w io.Writer, prefix string) io.Writer {
+	return &prefixWriter{w: w, prefix: []byte(prefix)}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i < 0 {
+			break
+		}
+		line := make([]byte, 0, len(p.prefix)+i+1)
+		line = append(append(line, p.prefix...), p.buf[:i+1]...)
+		if _, err := p.w.Write(line); err != nil {
+			return 0, err
+		}
+		p.buf = p.buf[i+1:]
+	}
+	return len(b), nil
+}
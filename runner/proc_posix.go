@@ -0,0 +1,44 @@
+// +build !windows
+
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+func (p *Process) spawn() *exec.Cmd {
+	cmd := exec.Command(p.Args[0], p.Args[1:]...)
+	cmd.Stdout = p.stdout()
+	cmd.Stderr = p.stderr()
+	return cmd
+}
+
+func (p *Process) terminate(cmd *exec.Cmd, sig os.Signal) error {
+	if sig == os.Kill {
+		return cmd.Process.Kill()
+	}
+	if err := cmd.Process.Signal(sig); err != nil {
+		p.Logger.Println(err)
+		return cmd.Process.Kill()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+			return nil
+		}
+		time.Sleep(100)
+	}
+	return cmd.Process.Kill()
+}
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func killProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
@@ -1,6 +1,6 @@
 // +build windows
 
-package goemon
+package runner
 
 import (
 	"fmt"
@@ -16,37 +16,41 @@ var (
 	procGenerateConsoleCtrlEvent = libkernel32.MustFindProc("GenerateConsoleCtrlEvent")
 )
 
-func (g *Goemon) spawn() error {
-	g.cmd = exec.Command(g.Args[0], g.Args[1:]...)
-	g.cmd.Stdout = os.Stdout
-	g.cmd.Stderr = os.Stderr
-	g.cmd.SysProcAttr = &syscall.SysProcAttr{
+func (p *Process) spawn() *exec.Cmd {
+	cmd := exec.Command(p.Args[0], p.Args[1:]...)
+	cmd.Stdout = p.stdout()
+	cmd.Stderr = p.stderr()
+	cmd.SysProcAttr = &syscall.SysProcAttr{
 		CreationFlags: syscall.CREATE_UNICODE_ENVIRONMENT | 0x00000200,
 	}
-	return g.cmd.Run()
+	return cmd
 }
 
 func kill(p *os.Process) error {
 	return exec.Command("taskkill", "/F", "/T", "/PID", fmt.Sprint(p.Pid)).Run()
 }
 
-func (g *Goemon) terminate(sig os.Signal) error {
-	if g.cmd != nil && g.cmd.Process != nil {
-		if err := interrupt(g.cmd.Process, sig); err != nil {
-			g.Logger.Println(err)
-			return kill(g.cmd.Process)
-		}
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+func killProcessGroup(p *os.Process) error {
+	return kill(p)
+}
 
-		deadline := time.Now().Add(5 * time.Second)
-		for time.Now().Before(deadline) {
-			if g.cmd.ProcessState != nil && g.cmd.ProcessState.Exited() {
-				return nil
-			}
-			time.Sleep(100)
+func (p *Process) terminate(cmd *exec.Cmd, sig os.Signal) error {
+	if err := interrupt(cmd.Process, sig); err != nil {
+		p.Logger.Println(err)
+		return kill(cmd.Process)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+			return nil
 		}
-		return kill(g.cmd.Process)
+		time.Sleep(100)
 	}
-	return nil
+	return kill(cmd.Process)
 }
 
 func interrupt(p *os.Process, sig os.Signal) error {
@@ -0,0 +1,132 @@
+// Package runner run commands and supervise the process of goemon
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/mattn/goemon/console"
+)
+
+// ErrTimeout is returned when the command is killed because of the timeout
+var ErrTimeout = errors.New("command timed out")
+
+// Shell return the command which run command with shell
+func Shell(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/c", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+// killWait is how long Run wait for the output after the command is killed
+// because of the timeout. Processes which left the process group can keep
+// the output open.
+var killWait = time.Second
+
+// Run start cmd and wait for it. The command and its children are killed
+// when timeout is exceeded. Zero timeout means no timeout. With timeout, the
+// command is started in new process group to kill children, so it can't read
+// the terminal and os.Stdin is not given.
+func Run(cmd *exec.Cmd, timeout time.Duration) error {
+	if timeout <= 0 {
+		return cmd.Run()
+	}
+	if cmd.Stdin == os.Stdin {
+		cmd.Stdin = nil
+	}
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+	}
+	killProcessGroup(cmd.Process)
+	select {
+	case err := <-done:
+		return fmt.Errorf("%w: %v", ErrTimeout, err)
+	case <-time.After(killWait):
+		return fmt.Errorf("%w: output is not closed", ErrTimeout)
+	}
+}
+
+// Process is the long-running process which is restarted by goemon
+type Process struct {
+	Args   []string
+	Logger *log.Logger
+
+	// OnStart is called when the process is started successfully
+	OnStart func()
+
+	// Stdout and Stderr are output of the process. console.Stdout and
+	// console.Stderr are used if nil.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	mutex sync.Mutex
+	cmd   *exec.Cmd
+}
+
+// NewProcess create new Process for args
+func NewProcess(args []string, logger *log.Logger) *Process {
+	return &Process{Args: args, Logger: logger}
+}
+
+func (p *Process) stdout() io.Writer {
+	if p.Stdout != nil {
+		return p.Stdout
+	}
+	return console.Stdout
+}
+
+func (p *Process) stderr() io.Writer {
+	if p.Stderr != nil {
+		return p.Stderr
+	}
+	return console.Stderr
+}
+
+func (p *Process) command() *exec.Cmd {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.cmd
+}
+
+// Run start the process and wait for it
+func (p *Process) Run() error {
+	cmd := p.spawn()
+	p.mutex.Lock()
+	p.cmd = cmd
+	p.mutex.Unlock()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	if p.OnStart != nil {
+		p.OnStart()
+	}
+	return cmd.Wait()
+}
+
+// Terminate send sig to the process, and kill it when it doesn't exit in
+// 5 seconds.
+func (p *Process) Terminate(sig os.Signal) error {
+	cmd := p.command()
+	if cmd == nil || cmd.Process == nil {
+		return nil
+	}
+	return p.terminate(cmd, sig)
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimitWriter(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	wo, we := NewLimitWriters(&stdout, &stderr, 5)
+	wo.Write([]byte("abc"))
+	we.Write([]byte("defg"))
+	wo.Write([]byte("hij"))
+	if stdout.String() != "abc" {
+		t.Fatalf("Should be %q but got %q", "abc", stdout.String())
+	}
+	if !strings.HasPrefix(stderr.String(), "de\n[goemon: output truncated") {
+		t.Fatalf("Should be truncated but got %q", stderr.String())
+	}
+
+	// the notice is written once
+	wo.Write([]byte("klm"))
+	if n := strings.Count(stdout.String()+stderr.String(), "truncated"); n != 1 {
+		t.Fatalf("Should be noticed once but got %d", n)
+	}
+
+	// the output reaches the limit exactly
+	stdout.Reset()
+	wo, _ = NewLimitWriters(&stdout, &stderr, 5)
+	wo.Write([]byte("abcde"))
+	wo.Write([]byte("f"))
+	if !strings.HasPrefix(stdout.String(), "abcde\n[goemon: output truncated") {
+		t.Fatalf("Should be truncated but got %q", stdout.String())
+	}
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewPrefixWriter(&out, "[api] ")
+	w.Write([]byte("listening\nconn"))
+	w.Write([]byte("ected\n"))
+	expected := "[api] listening\n[api] connected\n"
+	if out.String() != expected {
+		t.Fatalf("Should be %q but got %q", expected, out.String())
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sleep is not available")
+	}
+	// sleep is a grandchild which has the output of sh
+	for _, stdin := range []io.Reader{nil, os.Stdin} {
+		var out bytes.Buffer
+		cmd := Shell("sleep 6; echo hi")
+		cmd.Stdin = stdin
+		cmd.Stdout = &out
+		start := time.Now()
+		err := Run(cmd, 100*time.Millisecond)
+		if !errors.Is(err, ErrTimeout) {
+			t.Fatal("Should be ErrTimeout", err)
+		}
+		if time.Since(start) > 3*time.Second {
+			t.Fatal("Should be timed out")
+		}
+		if cmd.Stdin != nil {
+			t.Fatal("Should not read the terminal in the process group")
+		}
+	}
+
+	// children which left the process group don't block
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid is not available")
+	}
+	var out bytes.Buffer
+	cmd := Shell("setsid sleep 6 & sleep 6")
+	cmd.Stdout = &out
+	start := time.Now()
+	if err := Run(cmd, 100*time.Millisecond); !errors.Is(err, ErrTimeout) {
+		t.Fatal("Should be ErrTimeout", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Fatal("Should not wait for the output")
+	}
+}
+
+func TestProcess(t *testing.T) {
+	p := NewProcess([]string{"go", "version"}, log.New(ioutil.Discard, "", 0))
+	err := p.Terminate(os.Interrupt)
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	err = p.Run()
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+}
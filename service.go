@@ -0,0 +1,122 @@
+package goemon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var serviceNameRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// service run goemon with the configuration when the user logged in
+type service struct {
+	Name       string
+	Config     string
+	Dir        string
+	Executable string
+}
+
+func newService(file string) (*service, error) {
+	if file == "" {
+		file = "goemon.yml"
+	}
+	fn, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(fn); err != nil {
+		return nil, err
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(fn)
+	// projects which have same name are distinguished by hash of the path
+	sum := sha256.Sum256([]byte(fn))
+	return &service{
+		Name:       "goemon-" + serviceNameRe.ReplaceAllString(filepath.Base(dir), "-") + "-" + hex.EncodeToString(sum[:4]),
+		Config:     fn,
+		Dir:        dir,
+		Executable: exe,
+	}, nil
+}
+
+// InstallService register goemon running with the configuration file when
+// the user logged in. It uses launchd agent on macOS, systemd user unit on
+// Linux, and Task Scheduler on Windows.
+func InstallService(file string) error {
+	s, err := newService(file)
+	if err != nil {
+		return err
+	}
+	return s.install()
+}
+
+// UninstallService remove goemon registered by InstallService
+func UninstallService(file string) error {
+	s, err := newService(file)
+	if err != nil {
+		return err
+	}
+	return s.uninstall()
+}
+
+// launchdPlist return launchd agent for macOS
+func (s *service) launchdPlist(logDir string) string {
+	var buf bytes.Buffer
+	str := func(v string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(v))
+		return "<string>" + b.String() + "</string>"
+	}
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&buf, "\t<key>Label</key>\n\t%s\n", str("com.github.mattn."+s.Name))
+	fmt.Fprintf(&buf, "\t<key>ProgramArguments</key>\n\t<array>\n\t\t%s\n\t\t%s\n\t\t%s\n\t</array>\n",
+		str(s.Executable), str("-c"), str(s.Config))
+	fmt.Fprintf(&buf, "\t<key>WorkingDirectory</key>\n\t%s\n", str(s.Dir))
+	buf.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	buf.WriteString("\t<key>KeepAlive</key>\n\t<true/>\n")
+	log := filepath.Join(logDir, s.Name+".log")
+	fmt.Fprintf(&buf, "\t<key>StandardOutPath</key>\n\t%s\n", str(log))
+	fmt.Fprintf(&buf, "\t<key>StandardErrorPath</key>\n\t%s\n", str(log))
+	buf.WriteString("</dict>\n</plist>\n")
+	return buf.String()
+}
+
+// systemdUnit return systemd user unit for Linux. goemon notify systemd
+// when it is ready.
+func (s *service) systemdUnit() string {
+	quote := func(v string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`
+	}
+	return fmt.Sprintf(`[Unit]
+Description=goemon for %s
+
+[Service]
+Type=notify
+WorkingDirectory=%s
+ExecStart=%s -c %s
+Restart=on-failure
+
+[Install]
+WantedBy=default.target
+`, s.Dir, s.Dir, quote(s.Executable), quote(s.Config))
+}
+
+// schtasksArgs return arguments of schtasks to create the task which run at
+// logon on Windows. Windows services can't be installed for a user.
+func (s *service) schtasksArgs() []string {
+	run := fmt.Sprintf(`cmd /c cd /d "%s" && "%s" -c "%s"`, s.Dir, s.Executable, s.Config)
+	return []string{"/Create", "/F", "/SC", "ONLOGON", "/RL", "LIMITED", "/TN", `goemon\` + s.Name, "/TR", run}
+}
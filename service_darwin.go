@@ -0,0 +1,49 @@
+package goemon
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+func (s *service) plistPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", "com.github.mattn."+s.Name+".plist"), nil
+}
+
+func (s *service) install() error {
+	fn, err := s.plistPath()
+	if err != nil {
+		return err
+	}
+	logDir := filepath.Join(filepath.Dir(filepath.Dir(fn)), "Logs")
+	if err = os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(fn, []byte(s.launchdPlist(logDir)), 0644); err != nil {
+		return err
+	}
+	return launchctl("load", "-w", fn)
+}
+
+func (s *service) uninstall() error {
+	fn, err := s.plistPath()
+	if err != nil {
+		return err
+	}
+	if err = launchctl("unload", "-w", fn); err != nil {
+		return err
+	}
+	return os.Remove(fn)
+}
+
+func launchctl(args ...string) error {
+	cmd := exec.Command("launchctl", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
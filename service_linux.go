@@ -0,0 +1,54 @@
+package goemon
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+func (s *service) unitPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", s.Name+".service"), nil
+}
+
+func (s *service) install() error {
+	fn, err := s.unitPath()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(fn, []byte(s.systemdUnit()), 0644); err != nil {
+		return err
+	}
+	if err = systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", "--now", s.Name+".service")
+}
+
+func (s *service) uninstall() error {
+	fn, err := s.unitPath()
+	if err != nil {
+		return err
+	}
+	if err = systemctl("disable", "--now", s.Name+".service"); err != nil {
+		return err
+	}
+	if err = os.Remove(fn); err != nil {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+func systemctl(args ...string) error {
+	cmd := exec.Command("systemctl", append([]string{"--user"}, args...)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
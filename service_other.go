@@ -0,0 +1,16 @@
+// +build !darwin,!linux,!windows
+
+package goemon
+
+import (
+	"fmt"
+	"runtime"
+)
+
+func (s *service) install() error {
+	return fmt.Errorf("service is not supported on %s", runtime.GOOS)
+}
+
+func (s *service) uninstall() error {
+	return fmt.Errorf("service is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,24 @@
+package goemon
+
+import (
+	"os"
+	"os/exec"
+)
+
+func (s *service) install() error {
+	if err := schtasks(s.schtasksArgs()...); err != nil {
+		return err
+	}
+	return schtasks("/Run", "/TN", `goemon\`+s.Name)
+}
+
+func (s *service) uninstall() error {
+	return schtasks("/Delete", "/F", "/TN", `goemon\`+s.Name)
+}
+
+func schtasks(args ...string) error {
+	cmd := exec.Command("schtasks", args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
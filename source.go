@@ -0,0 +1,88 @@
+package goemon
+
+// EventSource is source of events. Events from all sources are dispatched to
+// tasks through the same pipeline as events of the file system. goemon has
+// sources for the file system, the webhook, manual triggers, polling of
+// tasks and replay. Others like watchman can be added with AddEventSource.
+type EventSource interface {
+	Events() <-chan Event
+	Close() error
+}
+
+// manualSource is EventSource which emit events triggered by API or internal
+// commands.
+type manualSource struct {
+	ch chan Event
+}
+
+func newManualSource() *manualSource {
+	return &manualSource{ch: make(chan Event, 100)}
+}
+
+// trigger emit event without blocking. It return false if the event is
+// dropped because events are not dispatched, for example before Run.
+func (s *manualSource) trigger(event Event) bool {
+	select {
+	case s.ch <- event:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *manualSource) Events() <-chan Event {
+	return s.ch
+}
+
+func (s *manualSource) Close() error {
+	return nil
+}
+
+// AddEventSource add source of events. Events are dispatched after the
+// configuration is loaded by Run.
+func (g *Goemon) AddEventSource(src EventSource) {
+	g.sourceMutex.Lock()
+	defer g.sourceMutex.Unlock()
+	if !g.started {
+		g.sources = append(g.sources, src)
+		return
+	}
+	g.startEventSource(src)
+}
+
+func (g *Goemon) startEventSources() {
+	g.sourceMutex.Lock()
+	defer g.sourceMutex.Unlock()
+	g.started = true
+	for _, src := range g.sources {
+		g.startEventSource(src)
+	}
+	g.sources = nil
+}
+
+func (g *Goemon) startEventSource(src EventSource) {
+	go func() {
+		defer g.recoverCrash()
+		for event := range src.Events() {
+			g.dispatch(event)
+		}
+	}()
+}
+
+// Trigger fire event for name. name is file name or event name like ":Foo".
+// It never block. Events are dropped with the log when too many events are
+// waiting, for example when Run is not called.
+func (g *Goemon) Trigger(name string) {
+	if !g.manual.trigger(NewEvent(name, Write)) {
+		g.Logger.Println("dropped event", name, "because events are not dispatched")
+	}
+}
+
+func (g *Goemon) dispatch(event Event) {
+	g.record(event)
+	g.countEvent()
+	if g.suspend(event) {
+		return
+	}
+	g.task(event)
+}
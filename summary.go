@@ -0,0 +1,86 @@
+package goemon
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// summaryDelay is duration of silence after which the burst of activity is
+// considered settled
+const summaryDelay = time.Second
+
+// summary count events and runs of tasks in the burst of activity
+type summary struct {
+	mutex    sync.Mutex
+	events   int
+	runs     int
+	failures int
+	start    time.Time
+	last     time.Time
+	timer    *time.Timer
+}
+
+func (s *summary) String() string {
+	return fmt.Sprintf("%d events, %d tasks run, %d failed in %v",
+		s.events, s.runs, s.failures, s.last.Sub(s.start).Round(time.Millisecond))
+}
+
+// touch mark activity. It must be called with mutex locked.
+func (g *Goemon) touch() {
+	s := &g.summary
+	now := time.Now()
+	if s.start.IsZero() {
+		s.start = now
+	}
+	s.last = now
+	if s.timer == nil {
+		s.timer = time.AfterFunc(summaryDelay, g.summarize)
+	} else {
+		s.timer.Reset(summaryDelay)
+	}
+}
+
+// countEvent count the dispatched event
+func (g *Goemon) countEvent() {
+	g.summary.mutex.Lock()
+	defer g.summary.mutex.Unlock()
+	g.summary.events++
+	g.touch()
+}
+
+// countRun count the finished run of the task
+func (g *Goemon) countRun(ok bool) {
+	g.summary.mutex.Lock()
+	defer g.summary.mutex.Unlock()
+	g.summary.runs++
+	if !ok {
+		g.summary.failures++
+	}
+	g.touch()
+}
+
+// summarize log the summary when the activity settled. It is also sent to
+// systemd with notify_summary.
+func (g *Goemon) summarize() {
+	s := &g.summary
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if atomic.LoadUint64(&g.tasks) > 0 {
+		s.timer.Reset(summaryDelay)
+		return
+	}
+	if s.runs > 0 {
+		line := s.String()
+		g.Logger.Println("summary:", line)
+		if g.conf.NotifySummary {
+			g.notify("STATUS=" + line)
+		}
+		if line := g.latency.String(); line != "" {
+			g.Logger.Println("latency:", line)
+		}
+	}
+	s.events, s.runs, s.failures = 0, 0, 0
+	s.start = time.Time{}
+}
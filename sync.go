@@ -0,0 +1,296 @@
+package goemon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mattn/goemon/watcher"
+)
+
+// syncer mirror the working directory into the destination and vice versa.
+// The destination is usually a directory shared with a container or a remote
+// machine. Changes made on the destination are copied back, then the watcher
+// of goemon fire tasks for them. At start, only the working directory is
+// mirrored because files in the destination may be stale.
+type syncer struct {
+	g    *Goemon
+	src  string
+	dest string
+	ire  *regexp.Regexp
+	sw   *fsnotify.Watcher
+	dw   *fsnotify.Watcher
+	done chan struct{}
+
+	// written hold hashes of files copied by syncer to ignore events of them
+	written map[string][sha256.Size]byte
+
+	// synced hold hashes of files which are same on both sides. Removal is
+	// propagated only to files which are not changed since then.
+	synced map[string][sha256.Size]byte
+}
+
+func (g *Goemon) startSync() error {
+	if g.syncer != nil {
+		g.syncer.Close()
+		g.syncer = nil
+	}
+	if g.conf.Sync.Dest == "" {
+		return nil
+	}
+	src, err := filepath.Abs(".")
+	if err != nil {
+		return err
+	}
+	dest, err := filepath.Abs(g.conf.Sync.Dest)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	s := &syncer{
+		g:       g,
+		src:     src,
+		dest:    dest,
+		done:    make(chan struct{}),
+		written: map[string][sha256.Size]byte{},
+		synced:  map[string][sha256.Size]byte{},
+	}
+	if g.conf.Sync.Ignore != "" {
+		s.ire, err = watcher.CompilePattern(g.conf.Sync.Ignore)
+		if err != nil {
+			return &ErrPatternInvalid{Task: -1, Pattern: g.conf.Sync.Ignore, Err: err}
+		}
+	}
+	if s.sw, err = fsnotify.NewWatcher(); err != nil {
+		return watcher.Exhausted(err)
+	}
+	if s.dw, err = fsnotify.NewWatcher(); err != nil {
+		s.sw.Close()
+		return watcher.Exhausted(err)
+	}
+	g.Logger.Println("syncing", src, "to", dest)
+	if err = s.mirror(src, dest, s.sw); err != nil {
+		s.Close()
+		return err
+	}
+	if err = s.watch(dest, s.dw); err != nil {
+		s.Close()
+		return err
+	}
+	go s.loop()
+	g.syncer = s
+	return nil
+}
+
+// Close stop syncing
+func (s *syncer) Close() error {
+	close(s.done)
+	s.sw.Close()
+	return s.dw.Close()
+}
+
+func (s *syncer) ignore(local string) bool {
+	rel, err := filepath.Rel(s.src, local)
+	if err != nil {
+		return true
+	}
+	if rel == ".git" || strings.HasPrefix(filepath.ToSlash(rel), ".git/") {
+		return true
+	}
+	return s.ire != nil && s.ire.MatchString(filepath.ToSlash(local))
+}
+
+// counterpart return the path which should be synchronized with name, and
+// whether name is in the working directory.
+func (s *syncer) counterpart(name string) (string, bool, error) {
+	if within(s.dest, name) {
+		rel, err := filepath.Rel(s.dest, name)
+		if err != nil {
+			return "", false, err
+		}
+		return filepath.Join(s.src, rel), false, nil
+	}
+	rel, err := filepath.Rel(s.src, name)
+	if err != nil {
+		return "", false, err
+	}
+	return filepath.Join(s.dest, rel), true, nil
+}
+
+// mirror copy all files under from into to, and watch directories under from.
+func (s *syncer) mirror(from, to string, w *fsnotify.Watcher) error {
+	return filepath.Walk(from, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == s.dest && from != s.dest {
+			return filepath.SkipDir
+		}
+		local := path
+		if to, fromSrc, err := s.counterpart(path); err == nil && !fromSrc {
+			local = to
+		}
+		if s.ignore(local) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(from, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := os.MkdirAll(filepath.Join(to, rel), 0755); err != nil {
+				return err
+			}
+			return watcher.Exhausted(w.Add(path))
+		}
+		return s.copy(path, filepath.Join(to, rel))
+	})
+}
+
+// watch watch directories under dir without copying files
+func (s *syncer) watch(dir string, w *fsnotify.Watcher) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if local, _, err := s.counterpart(path); err == nil && path != dir && s.ignore(local) {
+			return filepath.SkipDir
+		}
+		return watcher.Exhausted(w.Add(path))
+	})
+}
+
+func (s *syncer) loop() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case event := <-s.sw.Events:
+			s.sync(event, s.sw)
+		case event := <-s.dw.Events:
+			s.sync(event, s.dw)
+		case err := <-s.sw.Errors:
+			if err != nil {
+				s.g.Logger.Println("sync error:", err)
+			}
+		case err := <-s.dw.Errors:
+			if err != nil {
+				s.g.Logger.Println("sync error:", err)
+			}
+		}
+	}
+}
+
+func (s *syncer) sync(event fsnotify.Event, w *fsnotify.Watcher) {
+	to, fromSrc, err := s.counterpart(event.Name)
+	if err != nil {
+		s.g.Logger.Println(err)
+		return
+	}
+	local := event.Name
+	if !fromSrc {
+		local = to
+	}
+	if s.ignore(local) {
+		return
+	}
+	fi, err := os.Stat(event.Name)
+	if os.IsNotExist(err) {
+		s.remove(to)
+		return
+	}
+	if err != nil {
+		s.g.Logger.Println(err)
+		return
+	}
+	if fi.IsDir() {
+		if err = s.mirror(event.Name, to, w); err != nil {
+			s.g.Logger.Println(err)
+		}
+		return
+	}
+	if err = s.copy(event.Name, to); err != nil {
+		s.g.Logger.Println(err)
+	}
+}
+
+// remove remove name because the counterpart is removed. Files changed since
+// last sync and directories which are not empty are kept, so removal on one
+// side never lose changes on the other side.
+func (s *syncer) remove(name string) {
+	fi, err := os.Lstat(name)
+	if err != nil {
+		return
+	}
+	if !fi.IsDir() {
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			s.g.Logger.Println(err)
+			return
+		}
+		if h, ok := s.synced[name]; !ok || h != sha256.Sum256(b) {
+			s.g.Logger.Println("sync keep", name, "changed since last sync")
+			return
+		}
+		delete(s.synced, name)
+	}
+	s.g.Logger.Println("sync remove", name)
+	if err = os.Remove(name); err != nil {
+		s.g.Logger.Println(err)
+	}
+}
+
+// copy copy the file from src to dest only when the contents are different.
+// Events caused by the copy are ignored to prevent overwriting newer changes
+// with older contents.
+func (s *syncer) copy(src, dest string) error {
+	sb, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(sb)
+	if h, ok := s.written[src]; ok {
+		if h == sum {
+			return nil
+		}
+		delete(s.written, src)
+	}
+	if db, err := ioutil.ReadFile(dest); err == nil && bytes.Equal(sb, db) {
+		s.synced[src], s.synced[dest] = sum, sum
+		return nil
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, bytes.NewReader(sb))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		s.written[dest] = sum
+		s.synced[src], s.synced[dest] = sum, sum
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+package goemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var placeholderRe = regexp.MustCompile(`\{([a-z]+)((?:\|[^{}|]+)*)\}`)
+
+var templateFuncs = map[string]func(s string, args []string) (string, error){
+	"dir": func(s string, args []string) (string, error) {
+		return filepath.ToSlash(filepath.Dir(s)), nil
+	},
+	"base": func(s string, args []string) (string, error) {
+		return filepath.Base(s), nil
+	},
+	"ext": func(s string, args []string) (string, error) {
+		return filepath.Ext(s), nil
+	},
+	"trimext": func(s string, args []string) (string, error) {
+		return s[:len(s)-len(filepath.Ext(s))], nil
+	},
+	"abs": func(s string, args []string) (string, error) {
+		fn, err := filepath.Abs(s)
+		if err != nil {
+			return "", err
+		}
+		return filepath.ToSlash(fn), nil
+	},
+	"rel": func(s string, args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("rel requires 1 argument")
+		}
+		base, err := filepath.Abs(args[0])
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(base, s)
+		if err != nil {
+			return "", err
+		}
+		return filepath.ToSlash(rel), nil
+	},
+	"replace": func(s string, args []string) (string, error) {
+		if len(args) != 2 {
+			return "", fmt.Errorf("replace requires 2 arguments")
+		}
+		return strings.Replace(s, args[0], args[1], -1), nil
+	},
+}
+
+func (g *Goemon) placeholder(name, file string) (string, bool) {
+	switch name {
+	case "file":
+		return file, true
+	case "dir":
+		return filepath.ToSlash(filepath.Dir(file)), true
+	case "base":
+		return filepath.Base(file), true
+	case "ext":
+		return filepath.Ext(file), true
+	case "name":
+		fn := filepath.Base(file)
+		return fn[:len(fn)-len(filepath.Ext(fn))], true
+	case "branch":
+		return g.git.Branch(), true
+	}
+	return "", false
+}
+
+// expand replace placeholders like {file|dir} in the command. Unknown
+// placeholders are kept as is because braces are also used by shells.
+func (g *Goemon) expand(command, file string) (string, error) {
+	var rerr error
+	s := placeholderRe.ReplaceAllStringFunc(command, func(s string) string {
+		v, ok, err := g.expandPlaceholder(s, file)
+		if !ok || rerr != nil {
+			return s
+		}
+		if err != nil {
+			rerr = err
+			return s
+		}
+		return v
+	})
+	return s, rerr
+}
+
+// shellVarRe match placeholders and environment variables like $HOME or
+// ${GOEMON_TARGET_FILE} at the start
+var shellVarRe = regexp.MustCompile(`^(?:` + placeholderRe.String() + `|\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*))`)
+
+// expandShell is same as expand but values are quoted for shell, because
+// file names can contain characters of shell like ";". Environment variables
+// are expanded in the same pass, so expanded values are never expanded
+// again. Values in quotes of the command are escaped for the quotes.
+func (g *Goemon) expandShell(command, file string) (string, error) {
+	windows := runtime.GOOS == "windows"
+	var buf strings.Builder
+	var quote byte
+	for i := 0; i < len(command); {
+		c := command[i]
+		if ss := shellVarRe.FindStringSubmatch(command[i:]); ss != nil {
+			i += len(ss[0])
+			if ss[1] == "" {
+				name := ss[3] + ss[4]
+				if v, ok := g.targetVar(name, file); ok {
+					buf.WriteString(quoteIn(v, quote))
+				} else {
+					buf.WriteString(os.Getenv(name))
+				}
+				continue
+			}
+			v, ok, err := g.expandPlaceholder(ss[0], file)
+			if err != nil {
+				return "", err
+			}
+			if ok {
+				v = quoteIn(v, quote)
+			}
+			buf.WriteString(v)
+			continue
+		}
+		switch {
+		case windows:
+			if c == '"' {
+				quote ^= '"'
+			}
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(command):
+			buf.WriteByte(c)
+			i++
+			c = command[i]
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		}
+		buf.WriteByte(c)
+		i++
+	}
+	return buf.String(), nil
+}
+
+// targetVar return the value of the environment variable of goemon like
+// GOEMON_TARGET_FILE
+func (g *Goemon) targetVar(name, file string) (string, bool) {
+	switch name {
+	case "GOEMON_TARGET_FILE":
+		return file, true
+	case "GOEMON_TARGET_BASE":
+		return filepath.Base(file), true
+	case "GOEMON_TARGET_DIR":
+		return filepath.ToSlash(filepath.Dir(file)), true
+	case "GOEMON_TARGET_EXT":
+		return filepath.Ext(file), true
+	case "GOEMON_TARGET_NAME":
+		fn := filepath.Base(file)
+		return fn[:len(fn)-len(filepath.Ext(fn))], true
+	case "GOEMON_GIT_BRANCH":
+		return g.git.Branch(), true
+	case "GOEMON_GIT_DIRTY":
+		return g.git.Dirty(), true
+	}
+	return "", false
+}
+
+// shellSafeRe match strings which don't need quoting
+var shellSafeRe = regexp.MustCompile(`^[A-Za-z0-9_./:@+,-]+$`)
+
+// shellQuote quote s for the shell of runner.Shell if needed
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		if shellSafeRe.MatchString(strings.Replace(s, `\`, "/", -1)) {
+			return s
+		}
+		// file names can't contain double quotes on Windows
+		return `"` + strings.Replace(s, `"`, "", -1) + `"`
+	}
+	if shellSafeRe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// quoteIn quote s for the position in the quote of the command
+func quoteIn(s string, quote byte) string {
+	switch {
+	case quote == 0:
+		return shellQuote(s)
+	case runtime.GOOS == "windows":
+		return strings.Replace(s, `"`, "", -1)
+	case quote == '\'':
+		return strings.Replace(s, "'", `'\''`, -1)
+	}
+	return shellEscapeRe.ReplaceAllString(s, `\$0`)
+}
+
+// shellEscapeRe match characters which are special in double quotes
+var shellEscapeRe = regexp.MustCompile("[\"$`\\\\]")
+
+// expandPlaceholder return the value of the placeholder s like {file|dir}.
+// It returns false if s is unknown placeholder.
+func (g *Goemon) expandPlaceholder(s, file string) (string, bool, error) {
+	ss := placeholderRe.FindStringSubmatch(s)
+	v, ok := g.placeholder(ss[1], file)
+	if !ok || ss[2] == "" {
+		return v, ok, nil
+	}
+	for _, pipe := range strings.Split(ss[2][1:], "|") {
+		args := strings.Fields(pipe)
+		if len(args) == 0 {
+			continue
+		}
+		f, ok := templateFuncs[args[0]]
+		if !ok {
+			return "", true, fmt.Errorf("unknown function %q in %s", args[0], s)
+		}
+		var err error
+		v, err = f(v, args[1:])
+		if err != nil {
+			return "", true, fmt.Errorf("%v in %s", err, s)
+		}
+	}
+	return v, true, nil
+}
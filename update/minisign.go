@@ -0,0 +1,72 @@
+package update
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// ErrSignatureInvalid is returned when the signature of checksums.txt can't
+// be verified
+var ErrSignatureInvalid = errors.New("signature of checksums.txt is invalid")
+
+// publicKey is the public key of minisign
+type publicKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// parsePublicKey parse the public key like "RWQ...". The content of the
+// public key file which has the untrusted comment is accepted too.
+func parsePublicKey(s string) (*publicKey, error) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(b) != 2+8+ed25519.PublicKeySize || string(b[:2]) != "Ed" {
+		return nil, fmt.Errorf("invalid public key of minisign: %q", s)
+	}
+	return &publicKey{id: b[2:10], key: ed25519.PublicKey(b[10:])}, nil
+}
+
+// verifyMinisign verify b with the signature sig made by minisign. Both of
+// legacy signatures and prehashed signatures are supported. The trusted
+// comment is verified too.
+func verifyMinisign(key string, b, sig []byte) error {
+	pk, err := parsePublicKey(key)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.TrimSpace(string(sig)), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return fmt.Errorf("%w: malformed signature", ErrSignatureInvalid)
+	}
+	s, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(s) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrSignatureInvalid)
+	}
+	if !bytes.Equal(s[2:10], pk.id) {
+		return fmt.Errorf("%w: signed by other key", ErrSignatureInvalid)
+	}
+	switch string(s[:2]) {
+	case "Ed":
+	case "ED":
+		sum := blake2b.Sum512(b)
+		b = sum[:]
+	default:
+		return fmt.Errorf("%w: unknown algorithm", ErrSignatureInvalid)
+	}
+	if !ed25519.Verify(pk.key, b, s[10:]) {
+		return ErrSignatureInvalid
+	}
+
+	comment := strings.TrimSuffix(strings.TrimPrefix(lines[2], "trusted comment: "), "\r")
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || !ed25519.Verify(pk.key, append(append([]byte{}, s[10:]...), comment...), global) {
+		return fmt.Errorf("%w: trusted comment is modified", ErrSignatureInvalid)
+	}
+	return nil
+}
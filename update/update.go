@@ -0,0 +1,302 @@
+// Package update replace the running binary with the latest release
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUpToDate is returned when the current version is the latest
+var ErrUpToDate = errors.New("already up to date")
+
+// ChecksumFile is the name of the asset which contains SHA-256 checksums of
+// other assets in the format of sha256sum. It must be signed by minisign as
+// ChecksumFile+".minisig".
+const ChecksumFile = "checksums.txt"
+
+// Updater replace the binary with the asset of the latest release on GitHub.
+// The asset is the archive made by goxz like "NAME_TAG_GOOS_GOARCH.zip".
+type Updater struct {
+	// Repo is the repository like "mattn/goemon"
+	Repo string
+	// Name is the name of the binary
+	Name string
+	// Version is the current version
+	Version string
+	// API is the URL of GitHub API. https://api.github.com is used if empty.
+	API string
+	// Executable is the path to the binary to replace. The running binary
+	// is replaced if empty.
+	Executable string
+	// PublicKey is the public key of minisign which signed checksums.txt.
+	// Update fails without it.
+	PublicKey string
+
+	client *http.Client
+}
+
+// Release is the release on GitHub
+type Release struct {
+	Tag    string `json:"tag_name"`
+	Assets []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// New create new Updater
+func New(repo, name, version string) *Updater {
+	return &Updater{Repo: repo, Name: name, Version: version}
+}
+
+func (u *Updater) get(url string) ([]byte, error) {
+	if u.client == nil {
+		u.client = &http.Client{Timeout: 5 * time.Minute}
+	}
+	resp, err := u.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Latest return the latest release
+func (u *Updater) Latest() (*Release, error) {
+	api := u.API
+	if api == "" {
+		api = "https://api.github.com"
+	}
+	b, err := u.get(strings.TrimSuffix(api, "/") + "/repos/" + u.Repo + "/releases/latest")
+	if err != nil {
+		return nil, err
+	}
+	var r Release
+	if err = json.Unmarshal(b, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (r *Release) asset(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// Update replace the binary with the latest release if it is newer than
+// Version, and return the version installed. The asset is verified with
+// checksums.txt signed by PublicKey before replacing.
+func (u *Updater) Update() (string, error) {
+	r, err := u.Latest()
+	if err != nil {
+		return "", err
+	}
+	if !newer(r.Tag, u.Version) {
+		return "", ErrUpToDate
+	}
+
+	name := assetName(u.Name, r.Tag)
+	url := r.asset(name)
+	if url == "" {
+		return "", fmt.Errorf("%s is not found in release %s", name, r.Tag)
+	}
+	sums, err := u.checksums(r)
+	if err != nil {
+		return "", err
+	}
+	b, err := u.get(url)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	if expected, ok := sums[name]; !ok || expected != hex.EncodeToString(sum[:]) {
+		return "", fmt.Errorf("checksum of %s mismatch", name)
+	}
+	if b, err = extract(name, b, binaryName(u.Name)); err != nil {
+		return "", err
+	}
+
+	exe := u.Executable
+	if exe == "" {
+		if exe, err = os.Executable(); err != nil {
+			return "", err
+		}
+	}
+	return r.Tag, replace(exe, b)
+}
+
+// assetName return the name of the archive which goxz make for the release
+// like "goemon_v0.0.4_linux_amd64.tar.gz". It is zip on Windows and macOS.
+func assetName(name, tag string) string {
+	name += "_" + tag + "_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return name + ".zip"
+	}
+	return name + ".tar.gz"
+}
+
+func binaryName(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
+}
+
+// extract return the file named bin in the archive b
+func extract(name string, b []byte, bin string) ([]byte, error) {
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if path.Base(f.Name) != bin || !f.Mode().IsRegular() {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer r.Close()
+			return ioutil.ReadAll(r)
+		}
+		return nil, fmt.Errorf("%s is not found in %s", bin, name)
+	}
+
+	gr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s is not found in %s", bin, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if path.Base(h.Name) == bin && h.Typeflag == tar.TypeReg {
+			return ioutil.ReadAll(tr)
+		}
+	}
+}
+
+// checksums download checksums.txt and verify the signature of it. It fails
+// if the signature is missing.
+func (u *Updater) checksums(r *Release) (map[string]string, error) {
+	url := r.asset(ChecksumFile)
+	if url == "" {
+		return nil, fmt.Errorf("%s is not found in release %s", ChecksumFile, r.Tag)
+	}
+	if u.PublicKey == "" {
+		return nil, errors.New("public key to verify releases is not given")
+	}
+	sigURL := r.asset(ChecksumFile + ".minisig")
+	if sigURL == "" {
+		return nil, fmt.Errorf("%s.minisig is not found in release %s", ChecksumFile, r.Tag)
+	}
+	b, err := u.get(url)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := u.get(sigURL)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyMinisign(u.PublicKey, b, sig); err != nil {
+		return nil, err
+	}
+
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 {
+			sums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+		}
+	}
+	return sums, scanner.Err()
+}
+
+// replace write b into exe. The old binary is renamed at first because
+// running binary can't be overwritten on Windows.
+func replace(exe string, b []byte) error {
+	fi, err := os.Stat(exe)
+	if err != nil {
+		return err
+	}
+	tmp := exe + ".new"
+	if err = ioutil.WriteFile(tmp, b, fi.Mode()|0755); err != nil {
+		return err
+	}
+	old := exe + ".old"
+	os.Remove(old)
+	if err = os.Rename(exe, old); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, exe); err != nil {
+		os.Rename(old, exe)
+		return err
+	}
+	// this fails on Windows while the old binary is running
+	os.Remove(old)
+	return nil
+}
+
+// newer return true if version latest is newer than current
+func newer(latest, current string) bool {
+	l := versionNumbers(latest)
+	c := versionNumbers(current)
+	for i := 0; i < len(l) || i < len(c); i++ {
+		var a, b int
+		if i < len(l) {
+			a = l[i]
+		}
+		if i < len(c) {
+			b = c[i]
+		}
+		if a != b {
+			return a > b
+		}
+	}
+	return false
+}
+
+func versionNumbers(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var n []int
+	for _, s := range strings.Split(v, ".") {
+		i, _ := strconv.Atoi(s)
+		n = append(n, i)
+	}
+	return n
+}
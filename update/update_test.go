@@ -0,0 +1,225 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisign return the public key and the signature of b like minisign
+func minisign(t *testing.T, b []byte, prehash bool) (string, []byte) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := []byte("goemon!!")
+	alg := "Ed"
+	if prehash {
+		alg = "ED"
+		sum := blake2b.Sum512(b)
+		b = sum[:]
+	}
+	sig := ed25519.Sign(priv, b)
+	comment := "timestamp:1600000000"
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+	key := base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), pub...))
+	return key, []byte("untrusted comment: signature\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte(alg), id...), sig...)) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
+
+// archive return the archive like goxz which contains the binary b
+func archive(t *testing.T, name string, b []byte) []byte {
+	dir := strings.TrimSuffix(strings.TrimSuffix(name, ".zip"), ".tar.gz")
+	files := []struct {
+		name string
+		body []byte
+	}{
+		{dir + "/README.md", []byte("readme")},
+		{dir + "/" + binaryName("goemon"), b},
+	}
+	var buf bytes.Buffer
+	if strings.HasSuffix(name, ".zip") {
+		zw := zip.NewWriter(&buf)
+		for _, f := range files {
+			w, err := zw.Create(f.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write(f.body)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, f := range files {
+		tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0755, Size: int64(len(f.body)), Typeflag: tar.TypeReg})
+		tw.Write(f.body)
+	}
+	tw.Close()
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := assetName("goemon", "v0.0.4")
+	binary := []byte("new binary")
+	sum := sha256.Sum256(archive(t, name, binary))
+	checksums := hex.EncodeToString(sum[:]) + "  " + name + "\n"
+	key, sig := minisign(t, []byte(checksums), true)
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/mattn/goemon/releases/latest":
+			fmt.Fprintf(w, `{"tag_name":"v0.0.4","assets":[{"name":%q,"browser_download_url":%q},{"name":"checksums.txt","browser_download_url":%q},{"name":"checksums.txt.minisig","browser_download_url":%q}]}`,
+				name, ts.URL+"/dl/"+name, ts.URL+"/dl/checksums.txt", ts.URL+"/dl/checksums.txt.minisig")
+		case "/dl/" + name:
+			w.Write(archive(t, name, binary))
+		case "/dl/checksums.txt":
+			w.Write([]byte(checksums))
+		case "/dl/checksums.txt.minisig":
+			if sig == nil {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(sig)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	exe := filepath.Join(dir, "goemon")
+	ioutil.WriteFile(exe, []byte("old binary"), 0755)
+	u := New("mattn/goemon", "goemon", "0.0.3")
+	u.API = ts.URL
+	u.Executable = exe
+
+	if _, err = u.Update(); err == nil {
+		t.Fatal("Should not be succeeded without the public key")
+	}
+	u.PublicKey = key
+	if _, err = u.Update(); err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	b, _ := ioutil.ReadFile(exe)
+	if string(b) != "new binary" {
+		t.Fatalf("Should be replaced but got %q", string(b))
+	}
+
+	u.Version = "0.0.4"
+	if _, err = u.Update(); err != ErrUpToDate {
+		t.Fatal("Should be up to date", err)
+	}
+
+	u.Version = "0.0.3"
+	binary = []byte("tampered binary")
+	if _, err = u.Update(); err == nil {
+		t.Fatal("Should not be succeeded for checksum mismatch")
+	}
+	b, _ = ioutil.ReadFile(exe)
+	if string(b) != "new binary" {
+		t.Fatalf("Should not be replaced but got %q", string(b))
+	}
+
+	// checksums.txt can't be changed without the signature
+	sum = sha256.Sum256(archive(t, name, binary))
+	checksums = hex.EncodeToString(sum[:]) + "  " + name + "\n"
+	if _, err = u.Update(); !errors.Is(err, ErrSignatureInvalid) {
+		t.Fatal("Should be ErrSignatureInvalid", err)
+	}
+	sig = nil
+	if _, err = u.Update(); err == nil {
+		t.Fatal("Should not be succeeded without the signature")
+	}
+	b, _ = ioutil.ReadFile(exe)
+	if string(b) != "new binary" {
+		t.Fatalf("Should not be replaced but got %q", string(b))
+	}
+}
+
+func TestVerifyMinisign(t *testing.T) {
+	b := []byte("checksums")
+	for _, prehash := range []bool{false, true} {
+		key, sig := minisign(t, b, prehash)
+		if err := verifyMinisign(key, b, sig); err != nil {
+			t.Fatal("Should be succeeded", err)
+		}
+		if err := verifyMinisign("untrusted comment: minisign public key\n"+key+"\n", b, sig); err != nil {
+			t.Fatal("Should accept the public key file", err)
+		}
+		if err := verifyMinisign(key, []byte("tampered"), sig); !errors.Is(err, ErrSignatureInvalid) {
+			t.Fatal("Should be ErrSignatureInvalid", err)
+		}
+		tampered := strings.Replace(string(sig), "timestamp:", "timestamp:1", 1)
+		if err := verifyMinisign(key, b, []byte(tampered)); !errors.Is(err, ErrSignatureInvalid) {
+			t.Fatal("Should be ErrSignatureInvalid for trusted comment", err)
+		}
+		other, _ := minisign(t, b, prehash)
+		if err := verifyMinisign(other, b, sig); !errors.Is(err, ErrSignatureInvalid) {
+			t.Fatal("Should be ErrSignatureInvalid for other key", err)
+		}
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		latest, current string
+		expected        bool
+	}{
+		{"v0.0.4", "0.0.3", true},
+		{"v0.1.0", "0.0.10", true},
+		{"v0.0.3", "0.0.3", false},
+		{"v0.0.2", "0.0.3", false},
+		{"v1.0", "0.9.9", true},
+		{"v1.0.0-rc1", "1.0.0", false},
+	}
+	for _, test := range tests {
+		if got := newer(test.latest, test.current); got != test.expected {
+			t.Fatalf("newer(%q, %q) should be %v", test.latest, test.current, test.expected)
+		}
+	}
+}
+
+func TestExtract(t *testing.T) {
+	for _, name := range []string{"goemon_v0.0.4_linux_amd64.tar.gz", "goemon_v0.0.4_darwin_amd64.zip"} {
+		b, err := extract(name, archive(t, name, []byte("binary")), binaryName("goemon"))
+		if err != nil {
+			t.Fatal("Should be succeeded", err)
+		}
+		if string(b) != "binary" {
+			t.Fatalf("Should extract the binary but got %q", string(b))
+		}
+		if _, err = extract(name, archive(t, name, []byte("binary")), "other"); err == nil {
+			t.Fatal("Should not be succeeded without the binary")
+		}
+	}
+}
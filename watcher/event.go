@@ -0,0 +1,89 @@
+package watcher
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Op is set of operations on the file
+type Op uint32
+
+// Operations on the file
+const (
+	Create Op = 1 << iota
+	Write
+	Remove
+	Rename
+	Chmod
+)
+
+var opNames = []struct {
+	op   Op
+	name string
+}{
+	{Create, "CREATE"},
+	{Remove, "REMOVE"},
+	{Write, "WRITE"},
+	{Rename, "RENAME"},
+	{Chmod, "CHMOD"},
+}
+
+// String return names of operations joined with "|" like "CREATE|WRITE"
+func (op Op) String() string {
+	var names []string
+	for _, n := range opNames {
+		if op&n.op == n.op {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseOp parse name of operation like "write" or "CREATE"
+func ParseOp(op string) (Op, bool) {
+	for _, n := range opNames {
+		if strings.ToUpper(op) == n.name {
+			return n.op, true
+		}
+	}
+	return 0, false
+}
+
+// Event is the event of the file. Path is name of the file, or name of the
+// event like ":Foo" which is fired by commands.
+type Event struct {
+	Path string
+	Op   Op
+	Time time.Time
+}
+
+// NewEvent create new Event which happened now
+func NewEvent(path string, op Op) Event {
+	return Event{Path: path, Op: op, Time: time.Now()}
+}
+
+func (e Event) String() string {
+	return fmt.Sprintf("%q: %s", e.Path, e.Op)
+}
+
+func fromFsnotify(e fsnotify.Event) Event {
+	var op Op
+	for _, o := range []struct {
+		from fsnotify.Op
+		to   Op
+	}{
+		{fsnotify.Create, Create},
+		{fsnotify.Write, Write},
+		{fsnotify.Remove, Remove},
+		{fsnotify.Rename, Rename},
+		{fsnotify.Chmod, Chmod},
+	} {
+		if e.Op&o.from == o.from {
+			op |= o.to
+		}
+	}
+	return NewEvent(e.Name, op)
+}
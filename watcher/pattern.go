@@ -0,0 +1,121 @@
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// CompilePattern compile the pattern of goemon into regular expression.
+// Patterns are separated by "|" and relative paths are resolved from the
+// current directory. "**/" match any directories, "*" match any characters
+// except "/". The pattern which start with "%" is regular expression.
+func CompilePattern(pattern string) (*regexp.Regexp, error) {
+	return CompilePatternIn("", pattern)
+}
+
+// CompilePatternIn is same as CompilePattern but relative paths are
+// resolved from dir.
+func CompilePatternIn(dir, pattern string) (*regexp.Regexp, error) {
+	if pattern[0] == '%' {
+		return regexp.Compile(pattern[1:])
+	}
+
+	var buf bytes.Buffer
+
+	for n, pat := range strings.Split(pattern, "|") {
+		if n == 0 {
+			buf.WriteString("^")
+		} else {
+			buf.WriteString("$|")
+		}
+		if dir != "" && !filepath.IsAbs(pat) {
+			pat = filepath.Join(dir, pat)
+		}
+		if fs, err := filepath.Abs(pat); err == nil {
+			pat = filepath.ToSlash(fs)
+		}
+		rs := []rune(pat)
+		for i := 0; i < len(rs); i++ {
+			if rs[i] == '/' {
+				if runtime.GOOS == "windows" {
+					buf.WriteString(`[/\\]`)
+				} else {
+					buf.WriteRune(rs[i])
+				}
+			} else if rs[i] == '*' {
+				if i < len(rs)-1 && rs[i+1] == '*' {
+					i++
+					if i < len(rs)-1 && rs[i+1] == '/' {
+						i++
+						buf.WriteString(`.*`)
+					} else {
+						return nil, fmt.Errorf("invalid wildcard: %s", pattern)
+					}
+				} else {
+					buf.WriteString(`[^/]+`)
+				}
+			} else if rs[i] == '?' {
+				buf.WriteString(`\S`)
+			} else {
+				buf.WriteString(fmt.Sprintf(`[\x%x]`, rs[i]))
+			}
+		}
+		buf.WriteString("$")
+	}
+
+	return regexp.Compile(buf.String())
+}
+
+// PatternRoots return directories which contain all files matched by the
+// pattern, or the file itself for patterns without wildcards. It return nil
+// for regular expressions because they can match any files.
+func PatternRoots(dir, pattern string) []string {
+	if pattern == "" || pattern[0] == '%' {
+		return nil
+	}
+	var roots []string
+	for _, pat := range strings.Split(pattern, "|") {
+		if dir != "" && !filepath.IsAbs(pat) {
+			pat = filepath.Join(dir, pat)
+		}
+		pat, err := filepath.Abs(pat)
+		if err != nil {
+			return nil
+		}
+		if i := strings.IndexAny(pat, "*?"); i >= 0 {
+			pat = filepath.Dir(pat[:i+1])
+		}
+		roots = append(roots, pat)
+	}
+	return roots
+}
+
+// Matcher match events with compiled patterns. Match is required, Ignore is
+// optional. Ops is set of operations to match, or zero to match all.
+type Matcher struct {
+	Match  *regexp.Regexp
+	Ignore *regexp.Regexp
+	Ops    Op
+}
+
+// MatchFile return true if file is matched by Match and not by Ignore
+func (m *Matcher) MatchFile(file string) bool {
+	return (m.Match != nil && m.Match.MatchString(file)) && (m.Ignore == nil || !m.Ignore.MatchString(file))
+}
+
+// MatchOp return true if op is one of Ops
+func (m *Matcher) MatchOp(op Op) bool {
+	if m.Ops == 0 {
+		return true
+	}
+	return op&m.Ops == op
+}
+
+// MatchEvent return true if both of the file and the operation are matched
+func (m *Matcher) MatchEvent(event Event) bool {
+	return m.MatchFile(filepath.ToSlash(event.Path)) && m.MatchOp(event.Op)
+}
@@ -0,0 +1,115 @@
+// Package watcher watch files with fsnotify and match events with patterns
+// of goemon. It doesn't depend on livereload or running commands.
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// ErrWatcherExhausted is returned when no more files or directories can be
+// watched because the limit of the system is reached
+var ErrWatcherExhausted = errors.New("watcher exhausted")
+
+// Exhausted wrap err with ErrWatcherExhausted if err is caused by the limit
+// of the system.
+func Exhausted(err error) error {
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EMFILE) {
+		return fmt.Errorf("%w: %v", ErrWatcherExhausted, err)
+	}
+	return err
+}
+
+// Watcher watch files and directories
+type Watcher struct {
+	w    *fsnotify.Watcher
+	dup  map[string]bool
+	ch   chan Event
+	done chan struct{}
+	once sync.Once
+}
+
+// New create new Watcher
+func New() (*Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, Exhausted(err)
+	}
+	w := &Watcher{
+		w:    fw,
+		dup:  map[string]bool{},
+		ch:   make(chan Event),
+		done: make(chan struct{}),
+	}
+	go w.loop()
+	return w, nil
+}
+
+func (w *Watcher) loop() {
+	defer close(w.ch)
+	for e := range w.w.Events {
+		select {
+		case w.ch <- fromFsnotify(e):
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Add watch the file or the directory
+func (w *Watcher) Add(name string) error {
+	if err := w.w.Add(name); err != nil {
+		return Exhausted(err)
+	}
+	w.dup[name] = true
+	return nil
+}
+
+// AddTree watch root and directories under root which contain files
+// accepted by filter.
+func (w *Watcher) AddTree(root string, filter func(path string) bool) error {
+	if err := w.Add(root); err != nil {
+		return err
+	}
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if info == nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		dir := filepath.Dir(path)
+		if _, ok := w.dup[dir]; !ok && filter(path) {
+			if err := w.Add(dir); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Events return channel of events
+func (w *Watcher) Events() <-chan Event {
+	return w.ch
+}
+
+// Errors return channel of errors
+func (w *Watcher) Errors() <-chan error {
+	return w.w.Errors
+}
+
+// Close stop watching
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		close(w.done)
+		err = w.w.Close()
+	})
+	return err
+}
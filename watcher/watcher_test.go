@@ -0,0 +1,125 @@
+package watcher
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+
+	tests := []struct {
+		re   string
+		path string
+	}{
+		{`/path/**/*.txt`, `/path/to/file.txt`},
+	}
+	for _, test := range tests {
+		if runtime.GOOS == "windows" {
+			if p, err := filepath.Abs(test.path); err == nil {
+				test.path = p
+			}
+		}
+		re, err := CompilePattern(test.re)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !re.MatchString(test.path) {
+			t.Fatalf("%v should match as %v: %v", test.re, test.path, re.String())
+		}
+	}
+}
+
+func TestPatternRoots(t *testing.T) {
+	dir, _ := filepath.Abs("work")
+	roots := PatternRoots(dir, "./assets/*.js|./src/**/*.go|./main.go")
+	expected := []string{
+		filepath.Join(dir, "assets"),
+		filepath.Join(dir, "src"),
+		filepath.Join(dir, "main.go"),
+	}
+	if !reflect.DeepEqual(roots, expected) {
+		t.Fatalf("Should be %q but got %q", expected, roots)
+	}
+	if roots := PatternRoots(dir, `%\.go$`); roots != nil {
+		t.Fatalf("Should be nil for regular expressions but got %q", roots)
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	mre, err := CompilePattern("./assets/*.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ire, err := CompilePattern("./assets/*.min.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &Matcher{Match: mre, Ignore: ire, Ops: Create | Write}
+
+	name, _ := filepath.Abs(filepath.Join("assets", "a.js"))
+	if !m.MatchEvent(Event{Path: name, Op: Write}) {
+		t.Fatal("Should match", name)
+	}
+	if m.MatchEvent(Event{Path: name, Op: Remove}) {
+		t.Fatal("Should not match REMOVE")
+	}
+	name, _ = filepath.Abs(filepath.Join("assets", "a.min.js"))
+	if m.MatchEvent(Event{Path: name, Op: Write}) {
+		t.Fatal("Should ignore", name)
+	}
+}
+
+func TestAddTree(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "goemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "a"), 0755)
+	os.MkdirAll(filepath.Join(dir, "b"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "a", "foo.js"), []byte("a"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b", "foo.go"), []byte("b"), 0644)
+
+	w, err := New()
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	defer w.Close()
+	err = w.AddTree(dir, func(path string) bool {
+		return filepath.Ext(path) == ".js"
+	})
+	if err != nil {
+		t.Fatal("Should be succeeded", err)
+	}
+	if !w.dup[filepath.Join(dir, "a")] || w.dup[filepath.Join(dir, "b")] {
+		t.Fatal("Should watch only directories which contain matched files", w.dup)
+	}
+}
+
+func BenchmarkCompilePattern(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := CompilePattern("./assets/**/*.js|./src/*/*.go|./docs/**/*.md"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestOp(t *testing.T) {
+	op := Create | Write
+	if op.String() != "CREATE|WRITE" {
+		t.Fatal("Should be CREATE|WRITE:", op.String())
+	}
+	for _, s := range []string{"create", "WRITE", "Remove", "rename", "chmod"} {
+		if _, ok := ParseOp(s); !ok {
+			t.Fatal("Should parse", s)
+		}
+	}
+	if _, ok := ParseOp("foo"); ok {
+		t.Fatal("Should not parse foo")
+	}
+}
@@ -0,0 +1,114 @@
+package goemon
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/mattn/goemon/watcher"
+)
+
+// tokenHeader is the header which has the token of the webhook
+const tokenHeader = "X-Goemon-Token"
+
+type webhookRequest struct {
+	Paths []string `json:"paths"`
+	Op    string   `json:"op"`
+}
+
+// webhookSource is EventSource which emit events posted to the HTTP endpoint.
+// This is useful when files are changed on the machine which goemon can't
+// watch directly.
+type webhookSource struct {
+	g  *Goemon
+	ch chan Event
+}
+
+func newWebhookSource(g *Goemon) *webhookSource {
+	return &webhookSource{g: g, ch: make(chan Event, 100)}
+}
+
+func (s *webhookSource) Events() <-chan Event {
+	return s.ch
+}
+
+func (s *webhookSource) Close() error {
+	return nil
+}
+
+// ServeHTTP accept events with the token in GOEMON_WEBHOOK_TOKEN. The webhook
+// is disabled without the token.
+func (s *webhookSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.g.conf.Webhook {
+		http.NotFound(w, r)
+		return
+	}
+	token := os.Getenv("GOEMON_WEBHOOK_TOKEN")
+	if token == "" {
+		s.g.Logger.Println("webhook requires GOEMON_WEBHOOK_TOKEN")
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if err := checkRequest(r, token); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	s.serve(w, r)
+}
+
+// checkRequest return error if r doesn't have token, or r is sent by
+// browsers. Browsers can send requests to any address from any page, but
+// they add Origin and can't send JSON without it.
+func checkRequest(r *http.Request, token string) error {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(tokenHeader)), []byte(token)) != 1 {
+		return errors.New("invalid token")
+	}
+	if r.Header.Get("Origin") != "" {
+		return errors.New("cross-origin request")
+	}
+	if r.Method == http.MethodPost {
+		if ct, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || ct != "application/json" {
+			return errors.New("content type should be application/json")
+		}
+	}
+	return nil
+}
+
+func (s *webhookSource) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	op := Write
+	if req.Op != "" {
+		var ok bool
+		if op, ok = watcher.ParseOp(req.Op); !ok {
+			http.Error(w, fmt.Sprintf("unknown operation %v", req.Op), http.StatusBadRequest)
+			return
+		}
+	}
+	var names []string
+	for _, path := range req.Paths {
+		name, err := filepath.Abs(filepath.FromSlash(path))
+		if err != nil || !s.g.watched(name) {
+			http.Error(w, fmt.Sprintf("%v is not watched", path), http.StatusBadRequest)
+			return
+		}
+		names = append(names, name)
+	}
+	for _, name := range names {
+		s.g.Logger.Println("webhook", op, name)
+		s.ch <- NewEvent(name, op)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
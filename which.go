@@ -0,0 +1,94 @@
+package goemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// TaskInfo is the decision whether the task runs when the file is saved
+type TaskInfo struct {
+	// Match is the pattern of the task which is used as its name
+	Match    string   `json:"match"`
+	Commands []string `json:"commands"`
+	// Fire is true if the task runs
+	Fire bool `json:"fire"`
+	// Reason describe why the task runs or not
+	Reason string `json:"reason"`
+}
+
+// WhichTasks return all tasks with decisions whether they run when path is
+// saved. Relative path is resolved from the current directory.
+func (g *Goemon) WhichTasks(path string) []TaskInfo {
+	if fn, err := filepath.Abs(path); err == nil {
+		path = fn
+	}
+	file := filepath.ToSlash(path)
+
+	var infos []TaskInfo
+	if g.isDeps(file) {
+		infos = append(infos, TaskInfo{
+			Match:    g.conf.deps.Match,
+			Commands: g.conf.deps.Commands,
+			Fire:     true,
+			Reason:   "dependency file is changed",
+		})
+	}
+	fired := false
+	for _, t := range g.conf.Tasks {
+		info := TaskInfo{Match: t.Match, Commands: t.Commands}
+		switch {
+		case t.WatchURL != "" || t.WatchCmd != "":
+			info.Reason = "watching " + t.watchName() + " instead of files"
+		case strings.HasPrefix(t.Match, ":"):
+			info.Reason = "fired by event " + t.Match
+		case t.matcher.Match == nil || !t.matcher.Match.MatchString(file):
+			info.Reason = "not matched"
+		case t.matcher.Ignore != nil && t.matcher.Ignore.MatchString(file):
+			info.Reason = "ignored by " + t.Ignore
+		case !t.matchOp(Write):
+			info.Reason = "not fired by write"
+		case fired && g.conf.FirstMatch:
+			info.Reason = "skipped by first_match"
+		default:
+			info.Fire, fired = true, true
+			info.Reason = "matched " + t.Match
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (g *Goemon) serveWhich(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		http.Error(w, "path is required", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g.WhichTasks(path))
+}
+
+// Which ask goemon running on the same machine with the configuration which
+// tasks run when path is saved
+func (g *Goemon) Which(path string) ([]TaskInfo, error) {
+	if fn, err := filepath.Abs(path); err == nil {
+		path = fn
+	}
+	resp, err := g.controlRequest(http.MethodGet, "which?path="+url.QueryEscape(filepath.ToSlash(path)), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %v", resp.Status)
+	}
+	var infos []TaskInfo
+	if err = json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}